@@ -0,0 +1,428 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// Environments the application can run in
+const (
+	EnvDevelopment = "development"
+	EnvProduction  = "production"
+	EnvTest        = "test"
+)
+
+// minProductionSecretLength is the shortest JWT secret accepted in production
+const minProductionSecretLength = 32
+
+// Config is the application configuration. Load builds it from, in increasing
+// order of precedence: the defaults, an optional YAML or TOML file named by
+// CONFIG_FILE, and environment variables, which may come from an optional .env
+// file. Variables already set in the environment win over the .env file.
+type Config struct {
+	Env      string         `yaml:"env" toml:"env"`
+	Server   ServerConfig   `yaml:"server" toml:"server"`
+	Database DatabaseConfig `yaml:"database" toml:"database"`
+	Auth     AuthConfig     `yaml:"auth" toml:"auth"`
+	CORS     CORSConfig     `yaml:"cors" toml:"cors"`
+	Log      LogConfig      `yaml:"log" toml:"log"`
+	Tracing  TracingConfig  `yaml:"tracing" toml:"tracing"`
+}
+
+// ServerConfig configures the HTTP server. ShutdownTimeout bounds how long
+// in-flight requests get to finish once a shutdown signal arrives. Workout
+// export and import lift the read and write timeouts for their transfers.
+type ServerConfig struct {
+	Port              string   `yaml:"port" toml:"port"`
+	ReadTimeout       Duration `yaml:"read_timeout" toml:"read_timeout"`
+	ReadHeaderTimeout Duration `yaml:"read_header_timeout" toml:"read_header_timeout"`
+	WriteTimeout      Duration `yaml:"write_timeout" toml:"write_timeout"`
+	IdleTimeout       Duration `yaml:"idle_timeout" toml:"idle_timeout"`
+	ShutdownTimeout   Duration `yaml:"shutdown_timeout" toml:"shutdown_timeout"`
+}
+
+type DatabaseConfig struct {
+	Host     string `yaml:"host" toml:"host"`
+	Port     string `yaml:"port" toml:"port"`
+	User     string `yaml:"user" toml:"user"`
+	Password Secret `yaml:"password" toml:"password"`
+	Name     string `yaml:"name" toml:"name"`
+	SSLMode  string `yaml:"ssl_mode" toml:"ssl_mode"`
+}
+
+// AuthConfig configures the signing and lifetime of access and refresh tokens
+type AuthConfig struct {
+	JWTSecret       Secret   `yaml:"jwt_secret" toml:"jwt_secret"`
+	AccessTokenTTL  Duration `yaml:"access_token_ttl" toml:"access_token_ttl"`
+	RefreshTokenTTL Duration `yaml:"refresh_token_ttl" toml:"refresh_token_ttl"`
+}
+
+type CORSConfig struct {
+	AllowedOrigins []string `yaml:"allowed_origins" toml:"allowed_origins"`
+}
+
+// LogConfig configures the JSON logger. Level is debug, info, warn or error.
+type LogConfig struct {
+	Level string `yaml:"level" toml:"level"`
+}
+
+// Tracing exporters
+const (
+	TracingExporterNone   = "none"
+	TracingExporterStdout = "stdout"
+	TracingExporterOTLP   = "otlp"
+)
+
+// TracingConfig configures OpenTelemetry tracing. Spans are exported over
+// OTLP/HTTP to Endpoint, a collector's host:port, or written to stdout.
+// SampleRatio is the share of new traces recorded; traces started upstream
+// follow the caller's decision.
+type TracingConfig struct {
+	Exporter    string  `yaml:"exporter" toml:"exporter"`
+	Endpoint    string  `yaml:"endpoint" toml:"endpoint"`
+	Insecure    bool    `yaml:"insecure" toml:"insecure"`
+	ServiceName string  `yaml:"service_name" toml:"service_name"`
+	SampleRatio float64 `yaml:"sample_ratio" toml:"sample_ratio"`
+}
+
+// Secret is a configuration value that is never printed
+type Secret string
+
+// Reveal returns the secret's value
+func (s Secret) Reveal() string {
+	return string(s)
+}
+
+func (s Secret) String() string {
+	if s == "" {
+		return ""
+	}
+	return "[redacted]"
+}
+
+func (s Secret) GoString() string {
+	return strconv.Quote(s.String())
+}
+
+func (s Secret) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// Duration is a time.Duration written as a string such as "15m" in
+// configuration files and environment variables
+type Duration time.Duration
+
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	parsed, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// Default returns the configuration used for anything left unset
+func Default() Config {
+	return Config{
+		Env: EnvDevelopment,
+		Server: ServerConfig{
+			Port:              "8080",
+			ReadTimeout:       Duration(15 * time.Second),
+			ReadHeaderTimeout: Duration(5 * time.Second),
+			WriteTimeout:      Duration(30 * time.Second),
+			IdleTimeout:       Duration(60 * time.Second),
+			ShutdownTimeout:   Duration(20 * time.Second),
+		},
+		Database: DatabaseConfig{
+			Host:    "localhost",
+			Port:    "5432",
+			SSLMode: "disable",
+		},
+		Auth: AuthConfig{
+			AccessTokenTTL:  Duration(15 * time.Minute),
+			RefreshTokenTTL: Duration(7 * 24 * time.Hour),
+		},
+		CORS: CORSConfig{
+			AllowedOrigins: []string{"http://localhost:3000", "http://localhost:5173", "http://localhost:8080"},
+		},
+		Log: LogConfig{
+			Level: "info",
+		},
+		Tracing: TracingConfig{
+			Exporter:    TracingExporterNone,
+			Endpoint:    "localhost:4318",
+			Insecure:    true,
+			ServiceName: "trackle-api",
+			SampleRatio: 1,
+		},
+	}
+}
+
+// Load reads and validates the configuration. A missing .env file is not an
+// error; a missing CONFIG_FILE is.
+func Load() (*Config, error) {
+	cfg := Default()
+
+	if err := godotenv.Load(); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read .env: %w", err)
+	}
+
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		if err := cfg.readFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := cfg.readEnv(); err != nil {
+		return nil, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// readFile overlays the YAML or TOML file at path, chosen by its extension
+func (c *Config) readFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, c)
+	case ".toml":
+		err = toml.Unmarshal(data, c)
+	default:
+		return fmt.Errorf("config file %s must be .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// readEnv overlays the environment variables that are set
+func (c *Config) readEnv() error {
+	setString(&c.Env, "APP_ENV")
+	setString(&c.Server.Port, "SERVER_PORT")
+	durations := []struct {
+		target *Duration
+		name   string
+	}{
+		{&c.Server.ReadTimeout, "SERVER_READ_TIMEOUT"},
+		{&c.Server.ReadHeaderTimeout, "SERVER_READ_HEADER_TIMEOUT"},
+		{&c.Server.WriteTimeout, "SERVER_WRITE_TIMEOUT"},
+		{&c.Server.IdleTimeout, "SERVER_IDLE_TIMEOUT"},
+		{&c.Server.ShutdownTimeout, "SERVER_SHUTDOWN_TIMEOUT"},
+		{&c.Auth.AccessTokenTTL, "ACCESS_TOKEN_TTL"},
+		{&c.Auth.RefreshTokenTTL, "REFRESH_TOKEN_TTL"},
+	}
+	for _, d := range durations {
+		if err := setDuration(d.target, d.name); err != nil {
+			return err
+		}
+	}
+
+	setString(&c.Database.Host, "DB_HOST")
+	setString(&c.Database.Port, "DB_PORT")
+	setString(&c.Database.User, "DB_USER")
+	setSecret(&c.Database.Password, "DB_PASSWORD")
+	setString(&c.Database.Name, "DB_NAME")
+	setString(&c.Database.SSLMode, "SSL_MODE")
+
+	setSecret(&c.Auth.JWTSecret, "JWT_SECRET")
+	setString(&c.Log.Level, "LOG_LEVEL")
+
+	setString(&c.Tracing.Exporter, "TRACING_EXPORTER")
+	setString(&c.Tracing.Endpoint, "TRACING_ENDPOINT")
+	setString(&c.Tracing.ServiceName, "TRACING_SERVICE_NAME")
+	if err := setBool(&c.Tracing.Insecure, "TRACING_INSECURE"); err != nil {
+		return err
+	}
+	if err := setFloat(&c.Tracing.SampleRatio, "TRACING_SAMPLE_RATIO"); err != nil {
+		return err
+	}
+
+	if value, ok := os.LookupEnv("CORS_ALLOWED_ORIGINS"); ok {
+		c.CORS.AllowedOrigins = nil
+		for _, origin := range strings.Split(value, ",") {
+			if origin = strings.TrimSpace(origin); origin != "" {
+				c.CORS.AllowedOrigins = append(c.CORS.AllowedOrigins, origin)
+			}
+		}
+	}
+	return nil
+}
+
+func setString(target *string, name string) {
+	if value, ok := os.LookupEnv(name); ok {
+		*target = value
+	}
+}
+
+func setSecret(target *Secret, name string) {
+	if value, ok := os.LookupEnv(name); ok {
+		*target = Secret(value)
+	}
+}
+
+func setBool(target *bool, name string) error {
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return nil
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return fmt.Errorf("invalid %s: %w", name, err)
+	}
+	*target = parsed
+	return nil
+}
+
+func setFloat(target *float64, name string) error {
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return nil
+	}
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return fmt.Errorf("invalid %s: %w", name, err)
+	}
+	*target = parsed
+	return nil
+}
+
+func setDuration(target *Duration, name string) error {
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return nil
+	}
+	if err := target.UnmarshalText([]byte(value)); err != nil {
+		return fmt.Errorf("invalid %s: %w", name, err)
+	}
+	return nil
+}
+
+// Validate reports every problem with the configuration at once
+func (c *Config) Validate() error {
+	var problems []error
+	problem := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Errorf(format, args...))
+	}
+
+	switch c.Env {
+	case EnvDevelopment, EnvProduction, EnvTest:
+	default:
+		problem("env must be one of %s, %s or %s", EnvDevelopment, EnvProduction, EnvTest)
+	}
+
+	if port, err := strconv.Atoi(c.Server.Port); err != nil || port < 1 || port > 65535 {
+		problem("server port %q is not a valid port", c.Server.Port)
+	}
+	if c.Server.ReadTimeout <= 0 || c.Server.ReadHeaderTimeout <= 0 || c.Server.WriteTimeout <= 0 || c.Server.IdleTimeout <= 0 {
+		problem("server read, read header, write and idle timeouts must be positive")
+	}
+	if c.Server.ShutdownTimeout <= 0 {
+		problem("server shutdown timeout must be positive")
+	}
+
+	if c.Database.Host == "" {
+		problem("database host is required (DB_HOST)")
+	}
+	if port, err := strconv.Atoi(c.Database.Port); err != nil || port < 1 || port > 65535 {
+		problem("database port %q is not a valid port", c.Database.Port)
+	}
+	if c.Database.User == "" {
+		problem("database user is required (DB_USER)")
+	}
+	if c.Database.Name == "" {
+		problem("database name is required (DB_NAME)")
+	}
+	switch c.Database.SSLMode {
+	case "disable", "allow", "prefer", "require", "verify-ca", "verify-full":
+	default:
+		problem("database ssl mode %q is not a valid sslmode", c.Database.SSLMode)
+	}
+
+	if c.Auth.JWTSecret == "" {
+		problem("JWT secret is required (JWT_SECRET)")
+	} else if c.Env == EnvProduction && len(c.Auth.JWTSecret) < minProductionSecretLength {
+		problem("JWT secret must be at least %d characters in production", minProductionSecretLength)
+	}
+	if c.Auth.AccessTokenTTL <= 0 {
+		problem("access token TTL must be positive")
+	}
+	if c.Auth.RefreshTokenTTL <= c.Auth.AccessTokenTTL {
+		problem("refresh token TTL must be longer than the access token TTL")
+	}
+
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.Log.Level)); err != nil {
+		problem("log level %q must be debug, info, warn or error", c.Log.Level)
+	}
+
+	switch c.Tracing.Exporter {
+	case TracingExporterNone, TracingExporterStdout:
+	case TracingExporterOTLP:
+		if c.Tracing.Endpoint == "" {
+			problem("tracing endpoint is required for the otlp exporter (TRACING_ENDPOINT)")
+		}
+	default:
+		problem("tracing exporter must be one of %s, %s or %s", TracingExporterNone, TracingExporterStdout, TracingExporterOTLP)
+	}
+	if c.Tracing.ServiceName == "" {
+		problem("tracing service name is required (TRACING_SERVICE_NAME)")
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		problem("tracing sample ratio must be between 0 and 1")
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid configuration: %w", errors.Join(problems...))
+	}
+	return nil
+}
+
+// IsProduction reports whether the application runs in production
+func (c *Config) IsProduction() bool {
+	return c.Env == EnvProduction
+}
+
+// DSN returns the Postgres connection string of the database
+func (d DatabaseConfig) DSN() string {
+	return fmt.Sprintf(
+		"host=%s user=%s password=%s dbname=%s port=%s sslmode=%s",
+		d.Host, d.User, d.Password.Reveal(), d.Name, d.Port, d.SSLMode,
+	)
+}
+
+// String prints the configuration with its secrets redacted
+func (c Config) String() string {
+	out, err := yaml.Marshal(c)
+	if err != nil {
+		return fmt.Sprintf("%+v", struct{ Env string }{c.Env})
+	}
+	return string(out)
+}
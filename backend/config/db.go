@@ -2,46 +2,32 @@ package config
 
 import (
 	"fmt"
-	"github.com/rachitnimje/trackle-web/models"
-	"log"
-	"os"
+	"github.com/rachitnimje/trackle-web/migrations"
+	"log/slog"
 
-	"github.com/joho/godotenv"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
 
-func ConnectDB() *gorm.DB {
-	err := godotenv.Load()
+// ConnectDB opens a connection to the configured Postgres database
+func ConnectDB(cfg DatabaseConfig) (*gorm.DB, error) {
+	db, err := gorm.Open(postgres.Open(cfg.DSN()), &gorm.Config{})
 	if err != nil {
-		log.Fatal("Error loading .env file")
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
 	}
+	return db, nil
+}
 
-	dsn := fmt.Sprintf(
-		"host=%s user=%s password=%s dbname=%s port=%s sslmode=%s",
-		os.Getenv("DB_HOST"),
-		os.Getenv("DB_USER"),
-		os.Getenv("DB_PASSWORD"),
-		os.Getenv("DB_NAME"),
-		os.Getenv("DB_PORT"),
-		os.Getenv("SSL_MODE"),
-	)
-
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+// MigrateDB applies any pending schema migrations
+func MigrateDB(db *gorm.DB) error {
+	migrator, err := migrations.New(db)
 	if err != nil {
-		log.Fatal("Failed to connect to database: ", err)
+		return err
 	}
-	return db
-}
 
-func MigrateDB(db *gorm.DB) *gorm.DB {
-	db.AutoMigrate(
-		&models.User{},
-		&models.Exercise{},
-		&models.Template{},
-		&models.TemplateExercise{},
-		&models.Workout{},
-		&models.WorkoutEntry{},
-	)
-	return db
+	applied, err := migrator.Up()
+	for _, migration := range applied {
+		slog.Info("Applied migration", "version", migration.Version, "name", migration.Name)
+	}
+	return err
 }
@@ -0,0 +1,51 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/rachitnimje/trackle-web/models"
+	"gorm.io/gorm"
+)
+
+// defaultPermissions lists the permissions granted to each built-in role
+var defaultPermissions = map[string][]string{
+	models.RoleUser: {},
+	models.RoleAdmin: {
+		models.PermissionManageExercises,
+		models.PermissionManageUsers,
+	},
+}
+
+var permissionDescriptions = map[string]string{
+	models.PermissionManageExercises: "Create, update and delete exercises in the global catalog",
+	models.PermissionManageUsers:     "View users and change their roles",
+}
+
+// SeedRoles makes sure the built-in roles and permissions exist. It is safe to
+// run on every boot.
+func SeedRoles(db *gorm.DB) error {
+	permissions := make(map[string]models.Permission)
+	for name, description := range permissionDescriptions {
+		permission := models.Permission{Name: name, Description: description}
+		if err := db.Where(models.Permission{Name: name}).FirstOrCreate(&permission).Error; err != nil {
+			return fmt.Errorf("failed to seed permission %s: %w", name, err)
+		}
+		permissions[name] = permission
+	}
+
+	for roleName, permissionNames := range defaultPermissions {
+		role := models.Role{Name: roleName}
+		if err := db.Where(models.Role{Name: roleName}).FirstOrCreate(&role).Error; err != nil {
+			return fmt.Errorf("failed to seed role %s: %w", roleName, err)
+		}
+
+		var rolePermissions []models.Permission
+		for _, name := range permissionNames {
+			rolePermissions = append(rolePermissions, permissions[name])
+		}
+		if err := db.Model(&role).Association("Permissions").Replace(rolePermissions); err != nil {
+			return fmt.Errorf("failed to seed permissions for role %s: %w", roleName, err)
+		}
+	}
+	return nil
+}
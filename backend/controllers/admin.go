@@ -0,0 +1,114 @@
+package controllers
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/rachitnimje/trackle-web/models"
+	"github.com/rachitnimje/trackle-web/utils"
+)
+
+type UpdateUserRoleRequest struct {
+	Role string `json:"role" binding:"required"`
+}
+
+func GetAllRoles(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
+		var roles []models.Role
+		if err := db.Preload("Permissions").Order("name").Find(&roles).Error; err != nil {
+			appErr := utils.NewDatabaseError("Failed to fetch roles", err)
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
+		utils.SuccessResponse(c, "Roles retrieved successfully", roles)
+	}
+}
+
+func UpdateUserRole(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
+		userIDStr := c.Param("id")
+		if userIDStr == "" {
+			appErr := utils.NewInvalidInputError("User ID is required", nil)
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
+		userID, err := strconv.ParseUint(userIDStr, 10, 32)
+		if err != nil || userID == 0 {
+			appErr := utils.NewInvalidInputError("Invalid user ID", err)
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
+		var req UpdateUserRoleRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			appErr := utils.NewValidationError("Invalid request data", err)
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
+		// check the role exists
+		var role models.Role
+		if err := db.Where("name = ?", req.Role).First(&role).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				appErr := utils.NewInvalidInputError("Unknown role", nil)
+				utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			} else {
+				appErr := utils.NewDatabaseError("Failed to fetch role", err)
+				utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			}
+			return
+		}
+
+		var user models.User
+		if err := db.First(&user, userID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				appErr := utils.NewNotFoundError("User not found", nil)
+				utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			} else {
+				appErr := utils.NewDatabaseError("Failed to fetch user", err)
+				utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			}
+			return
+		}
+
+		// Use transaction manager for atomic operation
+		var appErr *utils.AppError
+		err = utils.TransactionManager(db, c, func(tx *gorm.DB) error {
+			if err := tx.Model(&user).Update("role", role.Name).Error; err != nil {
+				appErr = utils.NewDatabaseError("Failed to update user role", err)
+				return appErr
+			}
+
+			// Revoke the user's sessions so the new role is picked up on next login
+			if err := tx.Model(&models.RefreshToken{}).
+				Where("user_id = ? AND revoked_at IS NULL", user.ID).
+				Update("revoked_at", time.Now()).Error; err != nil {
+				appErr = utils.NewDatabaseError("Failed to revoke user sessions", err)
+				return appErr
+			}
+
+			return nil
+		})
+
+		if appErr != nil {
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+		if err != nil {
+			// the failed commit has been written
+			return
+		}
+
+		utils.SuccessResponse(c, "User role updated successfully", user)
+	}
+}
@@ -1,12 +1,16 @@
 package controllers
 
 import (
+	"errors"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 
+	"github.com/rachitnimje/trackle-web/metrics"
 	"github.com/rachitnimje/trackle-web/models"
 	"github.com/rachitnimje/trackle-web/utils"
 )
@@ -15,7 +19,10 @@ type RegisterRequest struct {
 	Username string `json:"username" binding:"required,username"`
 	Email    string `json:"email" binding:"required,email"`
 	Password string `json:"password" binding:"required,strongpassword"`
-	Role     string `json:"role"`
+	// WeightUnit is optional and defaults to kg
+	WeightUnit string `json:"weight_unit" binding:"omitempty,oneof=kg lb"`
+	// Timezone is an optional IANA timezone and defaults to UTC
+	Timezone string `json:"timezone" binding:"omitempty,timezone"`
 }
 
 type LoginRequest struct {
@@ -28,11 +35,19 @@ type RegisterResponse struct {
 }
 
 type LoginResponse struct {
-	Token string `json:"token"`
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
 }
 
 func Register(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
 		var req RegisterRequest
 		if err := c.ShouldBindJSON(&req); err != nil {
 			errorMsg := utils.ValidationErrorToText(err)
@@ -45,11 +60,6 @@ func Register(db *gorm.DB) gin.HandlerFunc {
 		// Username already validated by the binding tag
 		// Password already validated by the binding tag
 
-		role := req.Role
-		if role == "" {
-			role = "user"
-		}
-
 		// Check if user already exists
 		var existingUser models.User
 		if err := db.Where("email = ? OR username = ?", utils.TrimAndLower(req.Email), req.Username).First(&existingUser).Error; err == nil {
@@ -71,33 +81,47 @@ func Register(db *gorm.DB) gin.HandlerFunc {
 			Username: req.Username,
 			Email:    utils.TrimAndLower(req.Email),
 			Password: string(hashedPassword),
-			Role:     role,
+			// Self-registration always gets the default role; admins are
+			// promoted through the admin API
+			Role:       models.RoleUser,
+			WeightUnit: utils.UnitKg,
+			Timezone:   utils.DefaultTimezone,
+		}
+		if req.WeightUnit != "" {
+			user.WeightUnit = req.WeightUnit
+		}
+		if req.Timezone != "" {
+			user.Timezone = req.Timezone
 		}
 
 		// Use transaction manager for atomic operation
-		var createdUser models.User
-		utils.TransactionManager(db, c, func(tx *gorm.DB) error {
+		var appErr *utils.AppError
+		err = utils.TransactionManager(db, c, func(tx *gorm.DB) error {
 			if err := tx.Create(&user).Error; err != nil {
-				return utils.NewDatabaseError("Failed to create user", err)
+				appErr = utils.NewDatabaseError("Failed to create user", err)
+				return appErr
 			}
-			
-			// Store the created user for response
-			createdUser = user
 			return nil
 		})
 
-		if createdUser.ID > 0 {
-			response := RegisterResponse{
-				User: createdUser,
-			}
-
-			utils.CreatedResponse(c, "User created successfully", response)
+		if appErr != nil {
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
 		}
+		if err != nil {
+			// the failed commit has been written
+			return
+		}
+
+		metrics.Registrations.Inc()
+		utils.CreatedResponse(c, "User created successfully", RegisterResponse{User: user})
 	}
 }
 
-func Login(db *gorm.DB) gin.HandlerFunc {
+func Login(db *gorm.DB, jwtManager *utils.JWTManager) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
 		var req LoginRequest
 		if err := c.ShouldBindJSON(&req); err != nil {
 			errorMsg := utils.ValidationErrorToText(err)
@@ -109,6 +133,7 @@ func Login(db *gorm.DB) gin.HandlerFunc {
 		// Find user by email
 		var user models.User
 		if err := db.Where("email = ?", utils.TrimAndLower(req.Email)).First(&user).Error; err != nil {
+			metrics.FailedLogins.WithLabelValues(metrics.LoginFailureUnknownEmail).Inc()
 			appErr := utils.NewAuthenticationError("Invalid credentials", nil)
 			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
 			return
@@ -116,41 +141,157 @@ func Login(db *gorm.DB) gin.HandlerFunc {
 
 		// Verify password
 		if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
+			metrics.FailedLogins.WithLabelValues(metrics.LoginFailureWrongPassword).Inc()
 			appErr := utils.NewAuthenticationError("Invalid credentials", nil)
 			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
 			return
 		}
 
-		// Generate JWT token
-		token, err := utils.GenerateJWT(user.ID)
+		// Every login starts a new refresh token family (session)
+		familyID, err := utils.GenerateTokenFamilyID()
 		if err != nil {
 			appErr := utils.NewInternalError("Failed to generate token", err)
 			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
 			return
 		}
 
-		// Set cookie
-		c.SetSameSite(http.SameSiteLaxMode)
-		c.SetCookie("auth_token", token, 24*60*60, "/", "", false, true) // 24 hours
-
-		response := LoginResponse{
-			Token: token,
+		// Generate access and refresh tokens
+		_, response, err := issueTokens(db, jwtManager, user, familyID)
+		if err != nil {
+			appErr := utils.NewInternalError("Failed to generate token", err)
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
 		}
 
+		setAuthCookies(c, jwtManager, response)
 		utils.SuccessResponse(c, "Login successful", response)
 	}
 }
 
-func Logout() gin.HandlerFunc {
+// Refresh exchanges a refresh token for a new access/refresh token pair. The
+// presented token is revoked on use; presenting it again revokes its family.
+func Refresh(db *gorm.DB, jwtManager *utils.JWTManager) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// Clear the auth cookie
-		c.SetCookie("auth_token", "", -1, "/", "", false, true)
+		db := db.WithContext(c.Request.Context())
+
+		// Try to get refresh token from cookie first, then from the body
+		rawToken, err := c.Cookie("refresh_token")
+		if err != nil || rawToken == "" {
+			var req RefreshRequest
+			if err := c.ShouldBindJSON(&req); err != nil || req.RefreshToken == "" {
+				appErr := utils.NewAuthenticationError("Refresh token required", nil)
+				utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+				return
+			}
+			rawToken = req.RefreshToken
+		}
+
+		var response LoginResponse
+		var appErr *utils.AppError
+		err = utils.TransactionManager(db, c, func(tx *gorm.DB) error {
+			// Lock the token row so concurrent refreshes cannot both rotate it
+			var current models.RefreshToken
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+				Where("token_hash = ?", utils.HashRefreshToken(rawToken)).
+				First(&current).Error; err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					appErr = utils.NewAuthenticationError("Invalid refresh token", nil)
+					return nil
+				}
+				appErr = utils.NewDatabaseError("Failed to verify refresh token", err)
+				return appErr
+			}
+
+			// A revoked token being presented again means it has leaked, so the
+			// whole family is revoked and the user has to log in again. This must
+			// commit, hence returning nil.
+			if current.RevokedAt != nil {
+				if err := revokeTokenFamily(tx, current.FamilyID); err != nil {
+					appErr = utils.NewDatabaseError("Failed to revoke session", err)
+					return appErr
+				}
+				appErr = utils.NewAuthenticationError("Refresh token reuse detected", nil)
+				return nil
+			}
+
+			if time.Now().After(current.ExpiresAt) {
+				appErr = utils.NewAuthenticationError("Refresh token expired", nil)
+				return nil
+			}
+
+			// Reload the user so role changes are reflected in the new access token
+			var user models.User
+			if err := tx.First(&user, current.UserID).Error; err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					appErr = utils.NewAuthenticationError("User not found", nil)
+					return nil
+				}
+				appErr = utils.NewDatabaseError("Failed to load user", err)
+				return appErr
+			}
+
+			next, tokens, err := issueTokens(tx, jwtManager, user, current.FamilyID)
+			if err != nil {
+				appErr = utils.NewInternalError("Failed to generate token", err)
+				return appErr
+			}
+
+			// Rotate: revoke the presented token and link it to its replacement
+			if err := tx.Model(&current).Updates(map[string]interface{}{
+				"revoked_at":     time.Now(),
+				"replaced_by_id": next.ID,
+			}).Error; err != nil {
+				appErr = utils.NewDatabaseError("Failed to rotate refresh token", err)
+				return appErr
+			}
+
+			response = tokens
+			return nil
+		})
+
+		// a failed commit has been written already; any new tokens were rolled back
+		if err != nil && !errors.Is(err, appErr) {
+			return
+		}
+		if appErr != nil {
+			clearAuthCookies(c)
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
+		setAuthCookies(c, jwtManager, response)
+		utils.SuccessResponse(c, "Token refreshed successfully", response)
+	}
+}
+
+func Logout(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
+		sessionID, exists := c.Get("session_id")
+		if !exists {
+			appErr := utils.NewAuthenticationError("User not authenticated", nil)
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
+		// Revoke the session so its access and refresh tokens stop working
+		if err := revokeTokenFamily(db, sessionID.(string)); err != nil {
+			appErr := utils.NewDatabaseError("Failed to revoke session", err)
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
+		// Clear the auth cookies
+		clearAuthCookies(c)
 		utils.SuccessResponse(c, "Logged out successfully", nil)
 	}
 }
 
 func Me(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
 		userID, exists := c.Get("user_id")
 		if !exists {
 			appErr := utils.NewAuthenticationError("User not authenticated", nil)
@@ -168,3 +309,52 @@ func Me(db *gorm.DB) gin.HandlerFunc {
 		utils.SuccessResponse(c, "User retrieved successfully", user)
 	}
 }
+
+// issueTokens persists a new refresh token in the given family and signs an
+// access token bound to that family
+func issueTokens(db *gorm.DB, jwtManager *utils.JWTManager, user models.User, familyID string) (models.RefreshToken, LoginResponse, error) {
+	rawToken, tokenHash, err := utils.GenerateRefreshToken()
+	if err != nil {
+		return models.RefreshToken{}, LoginResponse{}, err
+	}
+
+	refreshToken := models.RefreshToken{
+		UserID:    user.ID,
+		TokenHash: tokenHash,
+		FamilyID:  familyID,
+		ExpiresAt: time.Now().Add(jwtManager.RefreshTokenTTL()),
+	}
+	if err := db.Create(&refreshToken).Error; err != nil {
+		return models.RefreshToken{}, LoginResponse{}, err
+	}
+
+	accessToken, err := jwtManager.Generate(user.ID, user.Role, familyID)
+	if err != nil {
+		return models.RefreshToken{}, LoginResponse{}, err
+	}
+
+	return refreshToken, LoginResponse{
+		Token:        accessToken,
+		RefreshToken: rawToken,
+		ExpiresIn:    int(jwtManager.AccessTokenTTL().Seconds()),
+	}, nil
+}
+
+// revokeTokenFamily revokes every live refresh token in a family, which also
+// invalidates access tokens issued for it
+func revokeTokenFamily(db *gorm.DB, familyID string) error {
+	return db.Model(&models.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", time.Now()).Error
+}
+
+func setAuthCookies(c *gin.Context, jwtManager *utils.JWTManager, tokens LoginResponse) {
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie("auth_token", tokens.Token, int(jwtManager.AccessTokenTTL().Seconds()), "/", "", false, true)
+	c.SetCookie("refresh_token", tokens.RefreshToken, int(jwtManager.RefreshTokenTTL().Seconds()), "/refresh", "", false, true)
+}
+
+func clearAuthCookies(c *gin.Context) {
+	c.SetCookie("auth_token", "", -1, "/", "", false, true)
+	c.SetCookie("refresh_token", "", -1, "/refresh", "", false, true)
+}
@@ -63,12 +63,24 @@ var equipmentTypes = []string{
 	"None",
 }
 
+var trackingTypes = []string{
+	models.TrackingWeightReps,
+	models.TrackingRepsOnly,
+	models.TrackingDuration,
+	models.TrackingDistanceDuration,
+}
+
 type CreateExerciseRequest struct {
 	Name          string `json:"name" binding:"required"`
 	Description   string `json:"description"`
 	Category      string `json:"category"`
 	PrimaryMuscle string `json:"primary_muscle"`
 	Equipment     string `json:"equipment"`
+	// TrackingType decides what a logged set records; defaults to weight_reps
+	TrackingType string `json:"tracking_type" binding:"omitempty,oneof=weight_reps reps_only duration distance_duration"`
+	// System adds the exercise to the global catalog instead of the caller's
+	// custom exercises. Requires the exercises:manage permission.
+	System bool `json:"system"`
 }
 
 type ExerciseResponse struct {
@@ -80,18 +92,34 @@ type ExerciseResponse struct {
 	Category      string `json:"category"`
 	PrimaryMuscle string `json:"primary_muscle"`
 	Equipment     string `json:"equipment"`
+	TrackingType  string `json:"tracking_type"`
+	UserID        *uint  `json:"user_id"`
+	IsCustom      bool   `json:"is_custom"`
 }
 
+// UpdateExerciseRequest replaces an exercise's details. The tracking type is
+// kept when omitted.
 type UpdateExerciseRequest struct {
 	Name          string `json:"name" binding:"required"`
 	Description   string `json:"description"`
 	Category      string `json:"category"`
 	PrimaryMuscle string `json:"primary_muscle"`
 	Equipment     string `json:"equipment"`
+	TrackingType  string `json:"tracking_type" binding:"omitempty,oneof=weight_reps reps_only duration distance_duration"`
 }
 
 func CreateExercise(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
+		// extract the user_id from context
+		userID, exists := c.Get("user_id")
+		if !exists {
+			appErr := utils.NewAuthenticationError("User not authenticated", nil)
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
 		var createExerciseRequest CreateExerciseRequest
 		if err := c.ShouldBindJSON(&createExerciseRequest); err != nil {
 			appErr := utils.NewValidationError("Invalid request data", err)
@@ -105,15 +133,34 @@ func CreateExercise(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
-		// check if exercise with the given name already exists
-		var count int64
-		if err := db.Model(&models.Exercise{}).Where("name = ?", createExerciseRequest.Name).Count(&count).Error; err != nil {
+		// custom exercises belong to the caller, system exercises to nobody
+		var ownerID *uint
+		if createExerciseRequest.System {
+			canManage, err := utils.HasPermission(db, c.GetString("role"), models.PermissionManageExercises)
+			if err != nil {
+				appErr := utils.NewDatabaseError("Failed to verify permissions", err)
+				utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+				return
+			}
+			if !canManage {
+				appErr := utils.NewAuthorizationError("Only admins can add exercises to the global catalog", nil)
+				utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+				return
+			}
+		} else {
+			uid := userID.(uint)
+			ownerID = &uid
+		}
+
+		// check if an exercise with the given name is already visible to the owner
+		taken, err := exerciseNameTaken(db, createExerciseRequest.Name, ownerID, 0)
+		if err != nil {
 			appErr := utils.NewDatabaseError("Failed to retrieve exercise with given name", err)
 			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
 			return
 		}
 
-		if count != 0 {
+		if taken {
 			appErr := utils.NewDuplicateEntryError("Exercise with the given name already exists", nil)
 			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
 			return
@@ -126,14 +173,26 @@ func CreateExercise(db *gorm.DB) gin.HandlerFunc {
 			Category:      createExerciseRequest.Category,
 			PrimaryMuscle: createExerciseRequest.PrimaryMuscle,
 			Equipment:     createExerciseRequest.Equipment,
+			TrackingType:  createExerciseRequest.TrackingType,
+			UserID:        ownerID,
+		}
+		if exercise.TrackingType == "" {
+			exercise.TrackingType = models.TrackingWeightReps
 		}
 
 		// Use transaction manager for atomic operation
 		var createdExercise models.Exercise
-		utils.TransactionManager(db, c, func(tx *gorm.DB) error {
-			// save the exercise to db
+		var appErr *utils.AppError
+		err = utils.TransactionManager(db, c, func(tx *gorm.DB) error {
+			// save the exercise to db; the unique name indexes catch a
+			// concurrent create with the same name
 			if err := tx.Create(&exercise).Error; err != nil {
-				return utils.NewDatabaseError("Failed to create exercise", err)
+				if utils.IsUniqueViolation(err) {
+					appErr = utils.NewDuplicateEntryError("Exercise with the given name already exists", nil)
+				} else {
+					appErr = utils.NewDatabaseError("Failed to create exercise", err)
+				}
+				return appErr
 			}
 
 			// Store the created exercise for response
@@ -141,14 +200,31 @@ func CreateExercise(db *gorm.DB) gin.HandlerFunc {
 			return nil
 		})
 
-		if createdExercise.ID > 0 {
-			utils.CreatedResponse(c, "Exercise created successfully", createdExercise)
+		if appErr != nil {
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
 		}
+		if err != nil {
+			// the failed commit has been written
+			return
+		}
+
+		utils.CreatedResponse(c, "Exercise created successfully", toExerciseResponse(createdExercise))
 	}
 }
 
 func GetAllExercises(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
+		// extract the user_id from context
+		userID, exists := c.Get("user_id")
+		if !exists {
+			appErr := utils.NewAuthenticationError("User not authenticated", nil)
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
 		// parse pagination parameters
 		page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
 		if err != nil || page < 1 {
@@ -165,10 +241,18 @@ func GetAllExercises(db *gorm.DB) gin.HandlerFunc {
 		category := c.Query("category")
 		search := c.Query("search")
 		muscle := c.Query("muscle")
+		scope := c.Query("scope")
 
-		query := db.Model(&models.Exercise{})
+		// merge the system catalog with the user's own custom exercises
+		query := visibleExercises(db.Model(&models.Exercise{}), userID)
 
-		// apply search, category, and muscle filters
+		// apply scope, search, category, and muscle filters
+		switch scope {
+		case "system":
+			query = query.Where("user_id IS NULL")
+		case "custom":
+			query = query.Where("user_id = ?", userID)
+		}
 		if category != "" {
 			query = query.Where("category = ?", category)
 		}
@@ -187,7 +271,7 @@ func GetAllExercises(db *gorm.DB) gin.HandlerFunc {
 		}
 
 		var exercises []models.Exercise
-		if err := query.Offset(offset).Limit(limit).Find(&exercises).Error; err != nil {
+		if err := query.Order("name").Offset(offset).Limit(limit).Find(&exercises).Error; err != nil {
 			appErr := utils.NewDatabaseError("Failed to fetch exercises", err)
 			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
 			return
@@ -195,17 +279,7 @@ func GetAllExercises(db *gorm.DB) gin.HandlerFunc {
 
 		var getExercisesResponse []ExerciseResponse
 		for _, exercise := range exercises {
-			exerciseResponse := ExerciseResponse{
-				ID:            strconv.Itoa(int(exercise.ID)),
-				CreatedAt:     exercise.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
-				UpdatedAt:     exercise.UpdatedAt.Format("2006-01-02T15:04:05Z07:00"),
-				Name:          exercise.Name,
-				Description:   exercise.Description,
-				Category:      exercise.Category,
-				PrimaryMuscle: exercise.PrimaryMuscle,
-				Equipment:     exercise.Equipment,
-			}
-			getExercisesResponse = append(getExercisesResponse, exerciseResponse)
+			getExercisesResponse = append(getExercisesResponse, toExerciseResponse(exercise))
 		}
 
 		utils.PaginatedResponse(c, "Exercises retrieved successfully", getExercisesResponse, page, limit, totalExercises)
@@ -214,6 +288,16 @@ func GetAllExercises(db *gorm.DB) gin.HandlerFunc {
 
 func GetExercise(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
+		// extract the user_id from context
+		userID, exists := c.Get("user_id")
+		if !exists {
+			appErr := utils.NewAuthenticationError("User not authenticated", nil)
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
 		exerciseID := c.Param("id")
 		if exerciseID == "" {
 			appErr := utils.NewInvalidInputError("Exercise ID is required", nil)
@@ -223,7 +307,8 @@ func GetExercise(db *gorm.DB) gin.HandlerFunc {
 
 		var exercise models.Exercise
 
-		if err := db.Where("id = ?", exerciseID).First(&exercise).Error; err != nil {
+		// other users' custom exercises are reported as not found
+		if err := visibleExercises(db, userID).Where("id = ?", exerciseID).First(&exercise).Error; err != nil {
 			if errors.Is(err, gorm.ErrRecordNotFound) {
 				appErr := utils.NewNotFoundError("Exercise not found", nil)
 				utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
@@ -234,24 +319,14 @@ func GetExercise(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
-		// Map exercise model to ExerciseResponse DTO
-		exerciseResponse := ExerciseResponse{
-			ID:            strconv.Itoa(int(exercise.ID)),
-			CreatedAt:     exercise.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
-			UpdatedAt:     exercise.UpdatedAt.Format("2006-01-02T15:04:05Z07:00"),
-			Name:          exercise.Name,
-			Description:   exercise.Description,
-			Category:      exercise.Category,
-			PrimaryMuscle: exercise.PrimaryMuscle,
-			Equipment:     exercise.Equipment,
-		}
-
-		utils.SuccessResponse(c, "Exercise retrieved successfully", exerciseResponse)
+		utils.SuccessResponse(c, "Exercise retrieved successfully", toExerciseResponse(exercise))
 	}
 }
 
 func UpdateExercise(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
 		exerciseIDStr := c.Param("id")
 		if exerciseIDStr == "" {
 			appErr := utils.NewInvalidInputError("Exercise ID is required", nil)
@@ -266,15 +341,9 @@ func UpdateExercise(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
-		// First check if the exercise exists
-		var exercise models.Exercise
-		if err := db.First(&exercise, exerciseID).Error; err != nil {
-			if errors.Is(err, gorm.ErrRecordNotFound) {
-				appErr := utils.NewNotFoundError("Exercise not found", nil)
-				utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
-				return
-			}
-			appErr := utils.NewDatabaseError("Failed to fetch exercise", err)
+		// First check if the exercise exists and the caller may modify it
+		exercise, appErr := findManageableExercise(db, c, uint(exerciseID))
+		if appErr != nil {
 			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
 			return
 		}
@@ -287,16 +356,16 @@ func UpdateExercise(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
-		// Check if the new name is already taken by another exercise
+		// Check if the new name is already taken by another exercise in the same scope
 		if updateExerciseRequest.Name != exercise.Name {
-			var count int64
-			if err := db.Model(&models.Exercise{}).Where("name = ? AND id != ?", updateExerciseRequest.Name, exerciseID).Count(&count).Error; err != nil {
+			taken, err := exerciseNameTaken(db, updateExerciseRequest.Name, exercise.UserID, exercise.ID)
+			if err != nil {
 				appErr := utils.NewDatabaseError("Failed to check for exercise name uniqueness", err)
 				utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
 				return
 			}
 
-			if count > 0 {
+			if taken {
 				appErr := utils.NewDuplicateEntryError("Exercise with the given name already exists", nil)
 				utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
 				return
@@ -309,26 +378,43 @@ func UpdateExercise(db *gorm.DB) gin.HandlerFunc {
 		exercise.Category = updateExerciseRequest.Category
 		exercise.PrimaryMuscle = updateExerciseRequest.PrimaryMuscle
 		exercise.Equipment = updateExerciseRequest.Equipment
+		if updateExerciseRequest.TrackingType != "" {
+			exercise.TrackingType = updateExerciseRequest.TrackingType
+		}
 
 		// Use transaction manager for atomic operation
 		var updatedExercise models.Exercise
-		utils.TransactionManager(db, c, func(tx *gorm.DB) error {
+		err = utils.TransactionManager(db, c, func(tx *gorm.DB) error {
 			if err := tx.Save(&exercise).Error; err != nil {
-				return utils.NewDatabaseError("Failed to update exercise", err)
+				if utils.IsUniqueViolation(err) {
+					appErr = utils.NewDuplicateEntryError("Exercise with the given name already exists", nil)
+				} else {
+					appErr = utils.NewDatabaseError("Failed to update exercise", err)
+				}
+				return appErr
 			}
 
 			updatedExercise = exercise
 			return nil
 		})
 
-		if updatedExercise.ID > 0 {
-			utils.SuccessResponse(c, "Exercise updated successfully", updatedExercise)
+		if appErr != nil {
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+		if err != nil {
+			// the failed commit has been written
+			return
 		}
+
+		utils.SuccessResponse(c, "Exercise updated successfully", toExerciseResponse(updatedExercise))
 	}
 }
 
 func DeleteExercise(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
 		exerciseIDStr := c.Param("id")
 		if exerciseIDStr == "" {
 			appErr := utils.NewInvalidInputError("Exercise ID is required", nil)
@@ -343,28 +429,32 @@ func DeleteExercise(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
-		// First check if the exercise exists
-		var exercise models.Exercise
-		if err := db.First(&exercise, exerciseID).Error; err != nil {
-			if errors.Is(err, gorm.ErrRecordNotFound) {
-				appErr := utils.NewNotFoundError("Exercise not found", nil)
-				utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
-				return
-			}
-			appErr := utils.NewDatabaseError("Failed to fetch exercise", err)
+		// First check if the exercise exists and the caller may modify it
+		exercise, appErr := findManageableExercise(db, c, uint(exerciseID))
+		if appErr != nil {
 			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
 			return
 		}
 
 		// Use transaction manager for atomic operation
-		utils.TransactionManager(db, c, func(tx *gorm.DB) error {
+		err = utils.TransactionManager(db, c, func(tx *gorm.DB) error {
 			// Delete the exercise
 			if err := tx.Delete(&exercise).Error; err != nil {
-				return utils.NewDatabaseError("Failed to delete exercise", err)
+				appErr = utils.NewDatabaseError("Failed to delete exercise", err)
+				return appErr
 			}
 			return nil
 		})
 
+		if appErr != nil {
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+		if err != nil {
+			// the failed commit has been written
+			return
+		}
+
 		utils.SuccessResponse(c, "Exercise deleted successfully", nil)
 	}
 }
@@ -380,3 +470,80 @@ func GetPrimaryMuscles(c *gin.Context) {
 func GetEquipmentTypes(c *gin.Context) {
 	utils.SuccessResponse(c, "Equipment types retrieved successfully", equipmentTypes)
 }
+
+func GetTrackingTypes(c *gin.Context) {
+	utils.SuccessResponse(c, "Tracking types retrieved successfully", trackingTypes)
+}
+
+// visibleExercises limits an exercise query to the system catalog plus the
+// given user's custom exercises
+func visibleExercises(query *gorm.DB, userID interface{}) *gorm.DB {
+	return query.Where("(exercises.user_id IS NULL OR exercises.user_id = ?)", userID)
+}
+
+// exerciseNameTaken reports whether name clashes with an exercise that shares
+// its scope. System exercises are visible to every user, so they clash with any
+// exercise; custom exercises clash with the system catalog and the owner's own.
+func exerciseNameTaken(db *gorm.DB, name string, ownerID *uint, excludeID uint) (bool, error) {
+	query := db.Model(&models.Exercise{}).Where("name = ? AND id != ?", name, excludeID)
+	if ownerID != nil {
+		query = visibleExercises(query, *ownerID)
+	}
+
+	var count int64
+	if err := query.Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// findManageableExercise loads an exercise the caller is allowed to modify:
+// their own custom exercise, or any exercise if they can manage the catalog
+func findManageableExercise(db *gorm.DB, c *gin.Context, exerciseID uint) (models.Exercise, *utils.AppError) {
+	var exercise models.Exercise
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		return exercise, utils.NewAuthenticationError("User not authenticated", nil)
+	}
+
+	canManage, err := utils.HasPermission(db, c.GetString("role"), models.PermissionManageExercises)
+	if err != nil {
+		return exercise, utils.NewDatabaseError("Failed to verify permissions", err)
+	}
+
+	query := db
+	if !canManage {
+		query = visibleExercises(db, userID)
+	}
+
+	if err := query.First(&exercise, exerciseID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return exercise, utils.NewNotFoundError("Exercise not found", nil)
+		}
+		return exercise, utils.NewDatabaseError("Failed to fetch exercise", err)
+	}
+
+	if !canManage && exercise.UserID == nil {
+		return exercise, utils.NewAuthorizationError("Only admins can modify exercises in the global catalog", nil)
+	}
+
+	return exercise, nil
+}
+
+// toExerciseResponse maps an exercise model to the ExerciseResponse DTO
+func toExerciseResponse(exercise models.Exercise) ExerciseResponse {
+	return ExerciseResponse{
+		ID:            strconv.Itoa(int(exercise.ID)),
+		CreatedAt:     exercise.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
+		UpdatedAt:     exercise.UpdatedAt.Format("2006-01-02T15:04:05Z07:00"),
+		Name:          exercise.Name,
+		Description:   exercise.Description,
+		Category:      exercise.Category,
+		PrimaryMuscle: exercise.PrimaryMuscle,
+		Equipment:     exercise.Equipment,
+		TrackingType:  exercise.TrackingType,
+		UserID:        exercise.UserID,
+		IsCustom:      exercise.UserID != nil,
+	}
+}
@@ -0,0 +1,415 @@
+package controllers
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/rachitnimje/trackle-web/utils"
+)
+
+// Export formats
+const (
+	exportCSV  = "csv"
+	exportJSON = "json"
+)
+
+// exportFlushEvery is how many rows are written between flushes to the client
+const exportFlushEvery = 100
+
+var exportCSVHeader = []string{
+	"workout_id", "workout_name", "template_id", "template_name", "performed_at", "logged_at",
+	"started_at", "finished_at", "duration_minutes", "notes",
+	"entry_id", "exercise_id", "exercise_name", "set_number", "set_type", "reps", "weight", "weight_unit",
+	"duration_seconds", "distance_meters", "rpe", "rir", "note",
+}
+
+// exportRow is one workout entry joined with its workout, template and
+// exercise. Workouts without entries produce a single row with no entry.
+type exportRow struct {
+	WorkoutID       uint
+	WorkoutName     string
+	TemplateID      uint
+	TemplateName    string
+	PerformedAt     time.Time
+	LoggedAt        time.Time
+	StartedAt       *time.Time
+	FinishedAt      *time.Time
+	DurationMinutes *int
+	Notes           string
+	EntryID         *uint
+	ExerciseID      *uint
+	ExerciseName    *string
+	SetNumber       *int
+	SetType         *string
+	Reps            *int
+	Weight          *float64
+	DurationSeconds *int
+	DistanceMeters  *float64
+	RPE             *float64
+	RIR             *int
+	Note            *string
+}
+
+type ExportWorkout struct {
+	WorkoutID       uint                 `json:"workout_id"`
+	WorkoutName     string               `json:"workout_name"`
+	TemplateID      uint                 `json:"template_id"`
+	TemplateName    string               `json:"template_name"`
+	PerformedAt     time.Time            `json:"performed_at"`
+	LoggedAt        time.Time            `json:"logged_at"`
+	StartedAt       *time.Time           `json:"started_at"`
+	FinishedAt      *time.Time           `json:"finished_at"`
+	DurationMinutes *int                 `json:"duration_minutes"`
+	Notes           string               `json:"notes"`
+	Entries         []ExportWorkoutEntry `json:"entries"`
+}
+
+type ExportWorkoutEntry struct {
+	EntryID         uint     `json:"entry_id"`
+	ExerciseID      uint     `json:"exercise_id"`
+	ExerciseName    string   `json:"exercise_name"`
+	SetNumber       int      `json:"set_number"`
+	SetType         string   `json:"set_type"`
+	Reps            int      `json:"reps"`
+	Weight          float64  `json:"weight"`
+	DurationSeconds *int     `json:"duration_seconds"`
+	DistanceMeters  *float64 `json:"distance_meters"`
+	RPE             *float64 `json:"rpe"`
+	RIR             *int     `json:"rir"`
+	Note            string   `json:"note"`
+}
+
+// ExportUserWorkouts streams all of the user's workouts and their entries as a
+// file download. Rows are read from the database and written to the client one
+// at a time, so the size of the history does not matter.
+//
+// Query parameters:
+//   - format: csv (default) or json
+//   - from, to: inclusive date range (YYYY-MM-DD) on the date the workout was
+//     performed, in the user's timezone
+//   - template_id: only workouts logged from this template
+func ExportUserWorkouts(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
+		// extract user_id from context
+		userID, exists := c.Get("user_id")
+		if !exists {
+			appErr := utils.NewAuthenticationError("User not authenticated", nil)
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
+		// the server's write timeout would cut a large export off partway
+		clearDeadlines(c)
+
+		format := c.DefaultQuery("format", exportCSV)
+		if format != exportCSV && format != exportJSON {
+			appErr := utils.NewInvalidInputError("Invalid export format", nil)
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
+		query := db.Table("workouts").
+			Select("workouts.id AS workout_id, workouts.name AS workout_name, workouts.template_id, "+
+				"templates.name AS template_name, workouts.performed_at, workouts.created_at AS logged_at, workouts.started_at, "+
+				"workouts.finished_at, workouts.duration_minutes, workouts.notes, "+
+				"workout_entries.id AS entry_id, workout_entries.exercise_id, exercises.name AS exercise_name, "+
+				"workout_entries.set_number, workout_entries.set_type, workout_entries.reps, workout_entries.weight, "+
+				"workout_entries.duration_seconds, workout_entries.distance_meters, workout_entries.rpe, workout_entries.rir, workout_entries.note").
+			// deleted templates and exercises keep their names in old workouts
+			Joins("LEFT JOIN templates ON templates.id = workouts.template_id").
+			Joins("LEFT JOIN workout_entries ON workout_entries.workout_id = workouts.id AND workout_entries.deleted_at IS NULL").
+			Joins("LEFT JOIN exercises ON exercises.id = workout_entries.exercise_id").
+			Where("workouts.deleted_at IS NULL AND workouts.user_id = ?", userID)
+
+		// dates are read and written in the user's timezone
+		loc, appErr := preferredLocation(db, userID)
+		if appErr != nil {
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
+		// Apply filters
+		if from := c.Query("from"); from != "" {
+			fromDate, err := time.ParseInLocation("2006-01-02", from, loc)
+			if err != nil {
+				appErr := utils.NewInvalidInputError("Invalid from date, expected YYYY-MM-DD", err)
+				utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+				return
+			}
+			query = query.Where("workouts.performed_at >= ?", fromDate)
+		}
+
+		if to := c.Query("to"); to != "" {
+			toDate, err := time.ParseInLocation("2006-01-02", to, loc)
+			if err != nil {
+				appErr := utils.NewInvalidInputError("Invalid to date, expected YYYY-MM-DD", err)
+				utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+				return
+			}
+			query = query.Where("workouts.performed_at < ?", toDate.AddDate(0, 0, 1))
+		}
+
+		if templateID := c.Query("template_id"); templateID != "" {
+			if _, err := strconv.ParseUint(templateID, 10, 32); err != nil {
+				appErr := utils.NewInvalidInputError("Invalid template ID", err)
+				utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+				return
+			}
+			query = query.Where("workouts.template_id = ?", templateID)
+		}
+
+		preferredUnit, appErr := preferredWeightUnit(db, userID)
+		if appErr != nil {
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
+		rows, err := query.
+			Order("workouts.performed_at, workouts.id, workout_entries.id").
+			Rows()
+		if err != nil {
+			appErr := utils.NewDatabaseError("Failed to export workouts", err)
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+		defer rows.Close()
+
+		fileName := fmt.Sprintf("trackle-export-%s.%s", time.Now().Format("2006-01-02"), format)
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, fileName))
+
+		// The status is sent with the first bytes, so a failure from here on can
+		// only cut the download short
+		if format == exportCSV {
+			err = writeCSVExport(c, db, rows, preferredUnit, loc)
+		} else {
+			err = writeJSONExport(c, db, rows, preferredUnit, loc)
+		}
+		if err != nil {
+			c.Error(utils.NewDatabaseError("Workout export interrupted", err))
+		}
+	}
+}
+
+// writeCSVExport writes one CSV line per entry
+func writeCSVExport(c *gin.Context, db *gorm.DB, rows *sql.Rows, unit string, loc *time.Location) error {
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+
+	writer := csv.NewWriter(c.Writer)
+	if err := writer.Write(exportCSVHeader); err != nil {
+		return err
+	}
+
+	written := 0
+	for rows.Next() {
+		var row exportRow
+		if err := db.ScanRows(rows, &row); err != nil {
+			return err
+		}
+
+		record := []string{
+			strconv.FormatUint(uint64(row.WorkoutID), 10),
+			row.WorkoutName,
+			strconv.FormatUint(uint64(row.TemplateID), 10),
+			row.TemplateName,
+			row.PerformedAt.In(loc).Format("2006-01-02T15:04:05Z07:00"),
+			row.LoggedAt.In(loc).Format("2006-01-02T15:04:05Z07:00"),
+			formatOptionalTime(row.StartedAt, loc),
+			formatOptionalTime(row.FinishedAt, loc),
+			formatOptionalInt(row.DurationMinutes),
+			row.Notes,
+			"", "", "", "", "", "", "", "", "", "", "", "", "",
+		}
+		if row.EntryID != nil {
+			record[10] = strconv.FormatUint(uint64(*row.EntryID), 10)
+			record[11] = strconv.FormatUint(uint64(*row.ExerciseID), 10)
+			record[12] = stringValue(row.ExerciseName)
+			record[13] = formatOptionalInt(row.SetNumber)
+			record[14] = stringValue(row.SetType)
+			record[15] = formatOptionalInt(row.Reps)
+			record[16] = strconv.FormatFloat(utils.DisplayWeight(floatValue(row.Weight), unit), 'f', -1, 64)
+			record[17] = unit
+			record[18] = formatOptionalInt(row.DurationSeconds)
+			if row.DistanceMeters != nil {
+				record[19] = strconv.FormatFloat(*row.DistanceMeters, 'f', -1, 64)
+			}
+			if row.RPE != nil {
+				record[20] = strconv.FormatFloat(*row.RPE, 'f', -1, 64)
+			}
+			record[21] = formatOptionalInt(row.RIR)
+			record[22] = stringValue(row.Note)
+		}
+
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+
+		written++
+		if written%exportFlushEvery == 0 {
+			writer.Flush()
+			c.Writer.Flush()
+		}
+	}
+
+	writer.Flush()
+	c.Writer.Flush()
+	if err := writer.Error(); err != nil {
+		return err
+	}
+	return rows.Err()
+}
+
+// writeJSONExport writes a JSON document with one object per workout. Rows
+// arrive ordered by workout, so only the workout being written is held in memory.
+func writeJSONExport(c *gin.Context, db *gorm.DB, rows *sql.Rows, unit string, loc *time.Location) error {
+	c.Header("Content-Type", "application/json; charset=utf-8")
+
+	if _, err := fmt.Fprintf(c.Writer, `{"weight_unit":%q,"timezone":%q,"workouts":[`, unit, loc.String()); err != nil {
+		return err
+	}
+
+	var current *ExportWorkout
+	written := 0
+	flushWorkout := func() error {
+		if current == nil {
+			return nil
+		}
+		data, err := json.Marshal(current)
+		if err != nil {
+			return err
+		}
+		if written > 0 {
+			if _, err := c.Writer.WriteString(","); err != nil {
+				return err
+			}
+		}
+		if _, err := c.Writer.Write(data); err != nil {
+			return err
+		}
+
+		written++
+		if written%exportFlushEvery == 0 {
+			c.Writer.Flush()
+		}
+		return nil
+	}
+
+	for rows.Next() {
+		var row exportRow
+		if err := db.ScanRows(rows, &row); err != nil {
+			return err
+		}
+
+		if current == nil || current.WorkoutID != row.WorkoutID {
+			if err := flushWorkout(); err != nil {
+				return err
+			}
+			current = &ExportWorkout{
+				WorkoutID:       row.WorkoutID,
+				WorkoutName:     row.WorkoutName,
+				TemplateID:      row.TemplateID,
+				TemplateName:    row.TemplateName,
+				PerformedAt:     row.PerformedAt.In(loc),
+				LoggedAt:        row.LoggedAt.In(loc),
+				StartedAt:       optionalTimeIn(row.StartedAt, loc),
+				FinishedAt:      optionalTimeIn(row.FinishedAt, loc),
+				DurationMinutes: row.DurationMinutes,
+				Notes:           row.Notes,
+				Entries:         []ExportWorkoutEntry{},
+			}
+		}
+
+		if row.EntryID != nil {
+			current.Entries = append(current.Entries, ExportWorkoutEntry{
+				EntryID:         *row.EntryID,
+				ExerciseID:      *row.ExerciseID,
+				ExerciseName:    stringValue(row.ExerciseName),
+				SetNumber:       intValue(row.SetNumber),
+				SetType:         stringValue(row.SetType),
+				Reps:            intValue(row.Reps),
+				Weight:          utils.DisplayWeight(floatValue(row.Weight), unit),
+				DurationSeconds: row.DurationSeconds,
+				DistanceMeters:  row.DistanceMeters,
+				RPE:             row.RPE,
+				RIR:             row.RIR,
+				Note:            stringValue(row.Note),
+			})
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if err := flushWorkout(); err != nil {
+		return err
+	}
+
+	if _, err := c.Writer.WriteString("]}"); err != nil {
+		return err
+	}
+	c.Writer.Flush()
+	return nil
+}
+
+func formatOptionalTime(t *time.Time, loc *time.Location) string {
+	if t == nil {
+		return ""
+	}
+	return t.In(loc).Format("2006-01-02T15:04:05Z07:00")
+}
+
+func optionalTimeIn(t *time.Time, loc *time.Location) *time.Time {
+	if t == nil {
+		return nil
+	}
+	local := t.In(loc)
+	return &local
+}
+
+func formatOptionalInt(i *int) string {
+	if i == nil {
+		return ""
+	}
+	return strconv.Itoa(*i)
+}
+
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func intValue(i *int) int {
+	if i == nil {
+		return 0
+	}
+	return *i
+}
+
+func floatValue(f *float64) float64 {
+	if f == nil {
+		return 0
+	}
+	return *f
+}
+
+// clearDeadlines lifts the server's read and write timeouts for a request whose
+// body or response may be too large to transfer within them
+func clearDeadlines(c *gin.Context) {
+	controller := http.NewResponseController(c.Writer)
+	if err := controller.SetReadDeadline(time.Time{}); err != nil {
+		utils.RequestLogger(c).Warn("failed to clear read deadline", "error", err.Error())
+	}
+	if err := controller.SetWriteDeadline(time.Time{}); err != nil {
+		utils.RequestLogger(c).Warn("failed to clear write deadline", "error", err.Error())
+	}
+}
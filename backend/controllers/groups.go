@@ -0,0 +1,181 @@
+package controllers
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+
+	"github.com/rachitnimje/trackle-web/models"
+	"github.com/rachitnimje/trackle-web/utils"
+)
+
+// ExerciseGroupRequest puts a template exercise in a superset, circuit or giant
+// set. The exercises of a group are consecutive and repeat the same ID, type
+// and rounds; each gets one set per round.
+type ExerciseGroupRequest struct {
+	ID     int    `json:"id" binding:"required,min=1"`
+	Type   string `json:"type" binding:"required,oneof=superset circuit giant_set"`
+	Rounds int    `json:"rounds" binding:"required,min=1"`
+}
+
+// ExerciseGroupResponse is a group of exercises with its exercises in the order
+// they are performed within a round
+type ExerciseGroupResponse struct {
+	GroupID     int    `json:"group_id"`
+	Type        string `json:"type"`
+	Rounds      int    `json:"rounds"`
+	ExerciseIDs []uint `json:"exercise_ids"`
+}
+
+// validateTemplateGroups checks that grouped exercises are consecutive, agree
+// on their group and have one set per round
+func validateTemplateGroups(exercises []CreateTemplateExerciseRequest) *utils.AppError {
+	groups := make(map[int]ExerciseGroupRequest)
+	members := make(map[int]int)
+	closed := make(map[int]bool)
+
+	previous := 0
+	for _, e := range exercises {
+		current := 0
+		if e.Group != nil {
+			current = e.Group.ID
+		}
+		if previous != 0 && current != previous {
+			closed[previous] = true
+		}
+		previous = current
+		if e.Group == nil {
+			continue
+		}
+
+		group := *e.Group
+		if closed[group.ID] {
+			return utils.NewInvalidInputError(fmt.Sprintf("Exercises of group %d must be consecutive", group.ID), nil)
+		}
+		if first, ok := groups[group.ID]; ok && first != group {
+			return utils.NewInvalidInputError(fmt.Sprintf("Exercises of group %d must share its type and rounds", group.ID), nil)
+		}
+		groups[group.ID] = group
+		members[group.ID]++
+
+		sets := e.Sets
+		if len(e.SetTargets) > 0 {
+			sets = len(e.SetTargets)
+		}
+		if sets != 0 && sets != group.Rounds {
+			return utils.NewInvalidInputError(fmt.Sprintf("Exercises of group %d need one set per round", group.ID), nil)
+		}
+	}
+
+	for id, count := range members {
+		if count < 2 {
+			return utils.NewInvalidInputError(fmt.Sprintf("Group %d needs at least two exercises", id), nil)
+		}
+	}
+	return nil
+}
+
+// applyGroup copies a group onto a template exercise
+func applyGroup(templateExercise *models.TemplateExercise, group *ExerciseGroupRequest) {
+	templateExercise.GroupID = nil
+	templateExercise.GroupType = nil
+	templateExercise.GroupRounds = nil
+	if group == nil {
+		return
+	}
+
+	id, groupType, rounds := group.ID, group.Type, group.Rounds
+	templateExercise.GroupID = &id
+	templateExercise.GroupType = &groupType
+	templateExercise.GroupRounds = &rounds
+}
+
+// templateGroups lists the groups of a template's ordered exercises
+func templateGroups(exercises []models.TemplateExercise) []ExerciseGroupResponse {
+	groups := []ExerciseGroupResponse{}
+	index := make(map[int]int)
+	for _, templateExercise := range exercises {
+		if templateExercise.GroupID == nil {
+			continue
+		}
+
+		i, ok := index[*templateExercise.GroupID]
+		if !ok {
+			i = len(groups)
+			index[*templateExercise.GroupID] = i
+			groups = append(groups, ExerciseGroupResponse{GroupID: *templateExercise.GroupID})
+			if templateExercise.GroupType != nil {
+				groups[i].Type = *templateExercise.GroupType
+			}
+			if templateExercise.GroupRounds != nil {
+				groups[i].Rounds = *templateExercise.GroupRounds
+			}
+		}
+		groups[i].ExerciseIDs = append(groups[i].ExerciseIDs, templateExercise.ExerciseID)
+	}
+	return groups
+}
+
+// validateEntryGroups checks that the sets of a workout sharing a group agree
+// on its type
+func validateEntryGroups(entries []WorkoutEntryRequest) *utils.AppError {
+	types := make(map[int]string)
+	for _, entry := range entries {
+		if entry.GroupID == nil {
+			continue
+		}
+		if groupType, ok := types[*entry.GroupID]; ok && groupType != entry.GroupType {
+			return utils.NewInvalidInputError(fmt.Sprintf("Sets of group %d must share its type", *entry.GroupID), nil)
+		}
+		types[*entry.GroupID] = entry.GroupType
+	}
+	return nil
+}
+
+// entryGroupType returns the stored group type of a set, nil when ungrouped
+func entryGroupType(groupID *int, groupType string) *string {
+	if groupID == nil || groupType == "" {
+		return nil
+	}
+	return &groupType
+}
+
+// workoutGroups lists the groups of a workout's entries. A group's rounds are
+// the highest set number logged in it.
+func workoutGroups(entries []models.WorkoutEntry) []ExerciseGroupResponse {
+	groups := []ExerciseGroupResponse{}
+	index := make(map[int]int)
+	seen := make(map[int]map[uint]bool)
+	for _, entry := range entries {
+		if entry.GroupID == nil {
+			continue
+		}
+
+		i, ok := index[*entry.GroupID]
+		if !ok {
+			i = len(groups)
+			index[*entry.GroupID] = i
+			seen[*entry.GroupID] = make(map[uint]bool)
+			groups = append(groups, ExerciseGroupResponse{GroupID: *entry.GroupID})
+			if entry.GroupType != nil {
+				groups[i].Type = *entry.GroupType
+			}
+		}
+		groups[i].Rounds = max(groups[i].Rounds, entry.SetNumber)
+		if !seen[*entry.GroupID][entry.ExerciseID] {
+			seen[*entry.GroupID][entry.ExerciseID] = true
+			groups[i].ExerciseIDs = append(groups[i].ExerciseIDs, entry.ExerciseID)
+		}
+	}
+	return groups
+}
+
+// templateExerciseGroup returns the group an exercise has in a template, so
+// sets logged live from the template keep it
+func templateExerciseGroup(db *gorm.DB, templateID, exerciseID uint) (*int, *string, error) {
+	var templateExercise models.TemplateExercise
+	err := db.Where("template_id = ? AND exercise_id = ?", templateID, exerciseID).
+		Limit(1).
+		Find(&templateExercise).Error
+	return templateExercise.GroupID, templateExercise.GroupType, err
+}
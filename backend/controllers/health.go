@@ -0,0 +1,98 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/rachitnimje/trackle-web/migrations"
+	"github.com/rachitnimje/trackle-web/models"
+	"github.com/rachitnimje/trackle-web/utils"
+)
+
+// readinessTimeout bounds each readiness check so a stuck database fails the
+// probe instead of hanging it
+const readinessTimeout = 2 * time.Second
+
+// Readiness check results
+const (
+	checkOK     = "ok"
+	checkFailed = "failed"
+)
+
+type ReadinessResponse struct {
+	Database   string                  `json:"database"`
+	Migrations MigrationStatusResponse `json:"migrations"`
+}
+
+type MigrationStatusResponse struct {
+	Status  string `json:"status"`
+	Applied int    `json:"applied"`
+	Pending int    `json:"pending"`
+}
+
+// Healthz reports that the process is up. It checks nothing else, so a
+// database outage does not get the instance restarted.
+func Healthz(c *gin.Context) {
+	utils.SuccessResponse(c, "OK", nil)
+}
+
+// Readyz reports whether the instance can serve traffic: the database answers
+// a ping and every migration has been applied. The embedded migrations are
+// loaded once, when the route is set up.
+func Readyz(db *gorm.DB) gin.HandlerFunc {
+	migrator, migratorErr := migrations.New(db)
+
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), readinessTimeout)
+		defer cancel()
+
+		response := ReadinessResponse{
+			Database:   checkOK,
+			Migrations: MigrationStatusResponse{Status: checkOK},
+		}
+
+		ready := true
+		if err := pingDB(ctx, db); err != nil {
+			response.Database = checkFailed
+			response.Migrations.Status = checkFailed
+			ready = false
+		} else if migratorErr != nil {
+			response.Migrations.Status = checkFailed
+			ready = false
+		} else if applied, pending, err := migrator.WithContext(ctx).Counts(); err != nil {
+			response.Migrations.Status = checkFailed
+			ready = false
+		} else {
+			response.Migrations.Applied = applied
+			response.Migrations.Pending = pending
+			if pending > 0 {
+				response.Migrations.Status = "pending"
+				ready = false
+			}
+		}
+
+		if !ready {
+			c.JSON(http.StatusServiceUnavailable, models.APIResponse{
+				Success: false,
+				Message: "Service not ready",
+				Data:    response,
+			})
+			return
+		}
+
+		utils.SuccessResponse(c, "Service ready", response)
+	}
+}
+
+// pingDB checks the database connection
+func pingDB(ctx context.Context, db *gorm.DB) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
@@ -0,0 +1,566 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/rachitnimje/trackle-web/imports"
+	"github.com/rachitnimje/trackle-web/metrics"
+	"github.com/rachitnimje/trackle-web/models"
+	"github.com/rachitnimje/trackle-web/utils"
+)
+
+// maxImportSize caps the size of an uploaded export
+const maxImportSize = 20 << 20
+
+// importMatchThreshold is the lowest name similarity at which an exported
+// exercise is mapped to an existing one instead of a new custom exercise
+const importMatchThreshold = 0.8
+
+// Import plan actions
+const (
+	importActionMatch  = "match"
+	importActionCreate = "create"
+)
+
+// ImportReport describes what an import does. A dry run returns it without
+// saving anything.
+type ImportReport struct {
+	Source          string                `json:"source"`
+	DryRun          bool                  `json:"dry_run"`
+	WeightUnit      string                `json:"weight_unit"`
+	Workouts        int                   `json:"workouts"`
+	Sets            int                   `json:"sets"`
+	SkippedWorkouts int                   `json:"skipped_workouts"`
+	FirstWorkoutAt  *time.Time            `json:"first_workout_at"`
+	LastWorkoutAt   *time.Time            `json:"last_workout_at"`
+	Exercises       []ImportExerciseMatch `json:"exercises"`
+	Templates       []ImportTemplateMatch `json:"templates"`
+	Warnings        []imports.Warning     `json:"warnings"`
+}
+
+// ImportExerciseMatch maps an exercise name from the export to an existing
+// exercise, or to a custom exercise the import creates
+type ImportExerciseMatch struct {
+	Name         string  `json:"name"`
+	Action       string  `json:"action"`
+	ExerciseID   *uint   `json:"exercise_id"`
+	ExerciseName string  `json:"exercise_name,omitempty"`
+	Score        float64 `json:"score"`
+	Sets         int     `json:"sets"`
+}
+
+// ImportTemplateMatch maps a workout name from the export to one of the user's
+// templates, or to a template the import creates
+type ImportTemplateMatch struct {
+	Name       string `json:"name"`
+	Action     string `json:"action"`
+	TemplateID *uint  `json:"template_id"`
+	Workouts   int    `json:"workouts"`
+}
+
+// ImportUserWorkouts imports workout history from a Strong or Hevy CSV export,
+// sent as the multipart field "file".
+//
+// Form fields:
+//   - source: strong or hevy; detected from the file when omitted
+//   - unit: kg or lb, the unit of Strong weights (defaults to the user's unit)
+//   - timezone: IANA timezone the export's dates are in (defaults to the user's)
+//   - dry_run: true (default) only reports what would be imported; false saves it
+//
+// Workouts that were already imported are skipped, so re-running an import is safe.
+func ImportUserWorkouts(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
+		// extract user_id from context
+		userID, exists := c.Get("user_id")
+		if !exists {
+			appErr := utils.NewAuthenticationError("User not authenticated", nil)
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
+		// uploads are capped by size rather than by the server's read timeout,
+		// and saving a long history can outlast its write timeout
+		clearDeadlines(c)
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImportSize)
+
+		fileHeader, err := c.FormFile("file")
+		if err != nil {
+			appErr := utils.NewInvalidInputError("An export file is required", err)
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
+		source := strings.ToLower(c.PostForm("source"))
+		if source != "" && source != imports.SourceStrong && source != imports.SourceHevy {
+			appErr := utils.NewInvalidInputError("Invalid import source", nil)
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
+		unit := c.PostForm("unit")
+		if unit != "" && !utils.IsValidWeightUnit(unit) {
+			appErr := utils.NewInvalidInputError("Invalid weight unit", nil)
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
+		preferredUnit, appErr := preferredWeightUnit(db, userID)
+		if appErr != nil {
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
+		// exports write local times without a zone
+		loc, appErr := preferredLocation(db, userID)
+		if appErr != nil {
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+		if timezone := c.PostForm("timezone"); timezone != "" {
+			if !utils.IsValidTimezone(timezone) {
+				appErr := utils.NewInvalidInputError("Invalid timezone", nil)
+				utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+				return
+			}
+			loc = utils.LoadTimezone(timezone)
+		}
+
+		dryRun := c.DefaultPostForm("dry_run", "true") != "false"
+
+		file, err := fileHeader.Open()
+		if err != nil {
+			appErr := utils.NewInvalidInputError("Failed to read the export file", err)
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+		defer file.Close()
+
+		result, err := imports.Parse(source, file, requestWeightUnit(unit, preferredUnit), loc)
+		if err != nil {
+			appErr := utils.NewInvalidInputError(fmt.Sprintf("Failed to parse the export: %v", err), err)
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
+		report, workouts, appErr := planImport(db, userID, result)
+		if appErr != nil {
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
+		if dryRun {
+			utils.SuccessResponse(c, "Import preview generated successfully", report)
+			return
+		}
+
+		if len(workouts) == 0 {
+			appErr := utils.NewInvalidInputError("There are no new workouts to import", nil)
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
+		// save everything or nothing
+		err = utils.TransactionManager(db, c, func(tx *gorm.DB) error {
+			if err := commitImport(tx, userID.(uint), result, &report, workouts); err != nil {
+				if !errors.As(err, &appErr) {
+					appErr = utils.NewDatabaseError("Failed to import workouts", err)
+				}
+				return appErr
+			}
+			return nil
+		})
+
+		if appErr != nil {
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+		if err != nil {
+			// the failed commit has been written
+			return
+		}
+
+		metrics.WorkoutsLogged.WithLabelValues(metrics.WorkoutSourceImport).Add(float64(report.Workouts))
+		report.DryRun = false
+		utils.CreatedResponse(c, "Workouts imported successfully", report)
+	}
+}
+
+// planImport maps the parsed export onto the user's exercises, templates and
+// existing workouts, and returns the report with the workouts still to import
+func planImport(db *gorm.DB, userID interface{}, result *imports.Result) (ImportReport, []imports.Workout, *utils.AppError) {
+	report := ImportReport{
+		Source:     result.Source,
+		DryRun:     true,
+		WeightUnit: result.Unit,
+		Exercises:  []ImportExerciseMatch{},
+		Templates:  []ImportTemplateMatch{},
+		Warnings:   result.Warnings,
+	}
+	if report.Warnings == nil {
+		report.Warnings = []imports.Warning{}
+	}
+
+	// skip workouts that were imported before
+	existing := make(map[string]bool)
+	if len(result.Workouts) > 0 {
+		var workouts []models.Workout
+		if err := db.Select("name", "started_at").
+			Where("user_id = ? AND started_at BETWEEN ? AND ?", userID,
+				result.Workouts[0].StartedAt, result.Workouts[len(result.Workouts)-1].StartedAt).
+			Find(&workouts).Error; err != nil {
+			return report, nil, utils.NewDatabaseError("Failed to check for imported workouts", err)
+		}
+		for _, workout := range workouts {
+			existing[importWorkoutKey(workout.Name, *workout.StartedAt)] = true
+		}
+	}
+
+	var pending []imports.Workout
+	for _, workout := range result.Workouts {
+		if existing[importWorkoutKey(importWorkoutName(workout), workout.StartedAt)] {
+			report.SkippedWorkouts++
+			continue
+		}
+		pending = append(pending, workout)
+	}
+
+	var exercises []models.Exercise
+	if err := visibleExercises(db.Model(&models.Exercise{}), userID).Find(&exercises).Error; err != nil {
+		return report, nil, utils.NewDatabaseError("Failed to load exercises", err)
+	}
+	exercisesByName := make(map[string]models.Exercise, len(exercises))
+	for _, exercise := range exercises {
+		exercisesByName[importExerciseKey(exercise.Name)] = exercise
+	}
+
+	var templates []models.Template
+	if err := db.Where("user_id = ?", userID).Find(&templates).Error; err != nil {
+		return report, nil, utils.NewDatabaseError("Failed to load templates", err)
+	}
+	templatesByName := make(map[string]models.Template, len(templates))
+	for _, template := range templates {
+		templatesByName[strings.ToLower(strings.TrimSpace(template.Name))] = template
+	}
+
+	// the exercise each planned exercise maps to, with the tracking type an
+	// exercise created by the import gets, to check the sets against
+	var plannedExercises []models.Exercise
+	exerciseIndex := make(map[string]int)
+	templateIndex := make(map[string]int)
+	var planned []imports.Workout
+	for _, workout := range pending {
+		// sets that do not fit their exercise's tracking type are skipped, as
+		// they would be rejected when logged by hand
+		var sets []imports.Set
+		for _, set := range workout.Sets {
+			exerciseKey := importExerciseKey(set.ExerciseName)
+			i, ok := exerciseIndex[exerciseKey]
+			if !ok {
+				name := strings.TrimSpace(set.ExerciseName)
+				match := ImportExerciseMatch{Name: name, Action: importActionCreate}
+				target := models.Exercise{Name: name, TrackingType: importTrackingType(name, pending)}
+				// an exercise with the same name is always reused, since a new one
+				// could not take its name
+				exercise, score := matchExercise(name, exercises)
+				if named, ok := exercisesByName[exerciseKey]; ok {
+					exercise, score = named, 1
+				}
+				if score >= importMatchThreshold {
+					exerciseID := exercise.ID
+					match.Action = importActionMatch
+					match.ExerciseID = &exerciseID
+					match.ExerciseName = exercise.Name
+					match.Score = score
+					target = exercise
+				}
+				i = len(report.Exercises)
+				exerciseIndex[exerciseKey] = i
+				report.Exercises = append(report.Exercises, match)
+				plannedExercises = append(plannedExercises, target)
+			}
+
+			exercise := plannedExercises[i]
+			if problem := entryMetricsProblem(exercise, set.Reps, set.Weight, set.DurationSeconds, set.DistanceMeters); problem != "" {
+				report.Warnings = append(report.Warnings, imports.Warning{
+					Line:    set.Line,
+					Message: fmt.Sprintf("skipped a set of %q: %s %s", set.ExerciseName, exercise.Name, problem),
+				})
+				continue
+			}
+			report.Exercises[i].Sets++
+			report.Sets++
+			sets = append(sets, set)
+		}
+
+		if len(sets) == 0 {
+			report.Warnings = append(report.Warnings, imports.Warning{
+				Message: fmt.Sprintf("workout %q on %s has no importable sets", workout.Name, workout.StartedAt.Format("2006-01-02")),
+			})
+			continue
+		}
+		workout.Sets = sets
+		planned = append(planned, workout)
+
+		report.Workouts++
+		if report.FirstWorkoutAt == nil {
+			firstWorkoutAt := workout.StartedAt
+			report.FirstWorkoutAt = &firstWorkoutAt
+		}
+		lastWorkoutAt := workout.StartedAt
+		report.LastWorkoutAt = &lastWorkoutAt
+
+		templateName := importWorkoutName(workout)
+		templateKey := strings.ToLower(templateName)
+		if i, ok := templateIndex[templateKey]; ok {
+			report.Templates[i].Workouts++
+		} else {
+			match := ImportTemplateMatch{Name: templateName, Action: importActionCreate, Workouts: 1}
+			if template, ok := templatesByName[templateKey]; ok {
+				templateID := template.ID
+				match.Action = importActionMatch
+				match.TemplateID = &templateID
+			}
+			templateIndex[templateKey] = len(report.Templates)
+			report.Templates = append(report.Templates, match)
+		}
+	}
+
+	// exercises whose every set was skipped are not matched or created
+	exerciseMatches := report.Exercises[:0]
+	for _, match := range report.Exercises {
+		if match.Sets > 0 {
+			exerciseMatches = append(exerciseMatches, match)
+		}
+	}
+	report.Exercises = exerciseMatches
+
+	return report, planned, nil
+}
+
+// commitImport creates the planned exercises, templates and workouts and
+// recomputes the user's personal records. The report is updated with the IDs
+// of what was created.
+func commitImport(tx *gorm.DB, userID uint, result *imports.Result, report *ImportReport, workouts []imports.Workout) error {
+	exerciseIDs := make(map[string]uint, len(report.Exercises))
+	for i, match := range report.Exercises {
+		key := importExerciseKey(match.Name)
+		if match.Action == importActionMatch {
+			exerciseIDs[key] = *match.ExerciseID
+			continue
+		}
+
+		// the name may have been taken since the import was planned
+		taken, err := exerciseNameTaken(tx, match.Name, &userID, 0)
+		if err != nil {
+			return utils.NewDatabaseError("Failed to check exercise name", err)
+		}
+		if taken {
+			return utils.NewDuplicateEntryError(fmt.Sprintf("Exercise %q already exists", match.Name), nil)
+		}
+
+		exercise := models.Exercise{
+			Name:         match.Name,
+			Description:  fmt.Sprintf("Imported from %s", result.Source),
+			Equipment:    importEquipment(match.Name),
+			TrackingType: importTrackingType(match.Name, workouts),
+			UserID:       &userID,
+		}
+		if err := tx.Create(&exercise).Error; err != nil {
+			return utils.NewDatabaseError("Failed to create exercise", err)
+		}
+		exerciseIDs[key] = exercise.ID
+		report.Exercises[i].ExerciseID = &exercise.ID
+		report.Exercises[i].ExerciseName = exercise.Name
+	}
+
+	templateIDs := make(map[string]uint, len(report.Templates))
+	for i, match := range report.Templates {
+		key := strings.ToLower(match.Name)
+		if match.Action == importActionMatch {
+			templateIDs[key] = *match.TemplateID
+			continue
+		}
+
+		template := models.Template{
+			Name:        match.Name,
+			Description: fmt.Sprintf("Imported from %s", result.Source),
+			UserID:      userID,
+		}
+		if err := tx.Create(&template).Error; err != nil {
+			return utils.NewDatabaseError("Failed to create template", err)
+		}
+
+		// the template takes its exercises from the first workout with its name
+		for _, workout := range workouts {
+			if strings.ToLower(importWorkoutName(workout)) == key {
+				if err := syncTemplateExercises(tx, template.ID, importTemplateExercises(workout, exerciseIDs), utils.UnitKg); err != nil {
+					return err
+				}
+				break
+			}
+		}
+
+		templateIDs[key] = template.ID
+		report.Templates[i].TemplateID = &template.ID
+	}
+
+	touched := make(map[uint]bool)
+	for _, imported := range workouts {
+		startedAt := imported.StartedAt
+		workout := models.Workout{
+			Name:            importWorkoutName(imported),
+			UserID:          userID,
+			TemplateID:      templateIDs[strings.ToLower(importWorkoutName(imported))],
+			Notes:           imported.Notes,
+			PerformedAt:     startedAt,
+			StartedAt:       &startedAt,
+			FinishedAt:      imported.FinishedAt,
+			DurationMinutes: workoutDuration(&startedAt, imported.FinishedAt),
+		}
+		if workout.FinishedAt == nil {
+			// imported workouts are never live sessions
+			workout.FinishedAt = &startedAt
+		}
+
+		if err := tx.Create(&workout).Error; err != nil {
+			return utils.NewDatabaseError("Failed to create workout", err)
+		}
+
+		setNumbers := make(map[uint]int)
+		entries := make([]models.WorkoutEntry, 0, len(imported.Sets))
+		for _, set := range imported.Sets {
+			exerciseID := exerciseIDs[importExerciseKey(set.ExerciseName)]
+			setNumbers[exerciseID]++
+			touched[exerciseID] = true
+			entries = append(entries, models.WorkoutEntry{
+				WorkoutID:       workout.ID,
+				ExerciseID:      exerciseID,
+				SetNumber:       setNumbers[exerciseID],
+				SetType:         entrySetType(set.SetType),
+				Reps:            set.Reps,
+				Weight:          utils.ToKg(set.Weight, result.Unit),
+				DurationSeconds: set.DurationSeconds,
+				DistanceMeters:  set.DistanceMeters,
+				RPE:             set.RPE,
+				Note:            set.Note,
+			})
+		}
+
+		if err := tx.Create(&entries).Error; err != nil {
+			return utils.NewDatabaseError("Failed to create workout entries", err)
+		}
+	}
+
+	exerciseIDList := make([]uint, 0, len(touched))
+	for exerciseID := range touched {
+		exerciseIDList = append(exerciseIDList, exerciseID)
+	}
+	return recomputePersonalRecords(tx, userID, exerciseIDList)
+}
+
+// matchExercise returns the exercise whose name best matches name, with its
+// similarity score. An exercise's equipment counts as part of its name, so
+// "Bench Press (Barbell)" matches "Bench Press" with barbell equipment. When
+// the equipment differs the name alone still matches, at a lower score.
+func matchExercise(name string, exercises []models.Exercise) (models.Exercise, float64) {
+	baseName := name
+	if open := strings.LastIndex(name, "("); open > 0 {
+		baseName = strings.TrimSpace(name[:open])
+	}
+
+	var best models.Exercise
+	var bestScore float64
+	for _, exercise := range exercises {
+		score := utils.ExerciseNameSimilarity(name, exercise.Name)
+		if exercise.Equipment != "" {
+			score = max(score, utils.ExerciseNameSimilarity(name, exercise.Name+" "+exercise.Equipment))
+		}
+		if baseName != name {
+			score = max(score, 0.9*utils.ExerciseNameSimilarity(baseName, exercise.Name))
+		}
+		if score > bestScore {
+			best, bestScore = exercise, score
+		}
+	}
+	return best, bestScore
+}
+
+// importEquipment reads the equipment from names such as "Squat (Barbell)"
+func importEquipment(name string) string {
+	open, end := strings.LastIndex(name, "("), strings.LastIndex(name, ")")
+	if open < 0 || end < open {
+		return ""
+	}
+	equipment := strings.TrimSpace(name[open+1 : end])
+	for _, known := range equipmentTypes {
+		if strings.EqualFold(known, equipment) {
+			return known
+		}
+	}
+	return ""
+}
+
+// importTrackingType infers how a new exercise is tracked from its imported
+// sets: any distance makes it cardio, and time without reps a timed exercise
+func importTrackingType(name string, workouts []imports.Workout) string {
+	var reps, duration bool
+	for _, workout := range workouts {
+		for _, set := range workout.Sets {
+			if importExerciseKey(set.ExerciseName) != importExerciseKey(name) {
+				continue
+			}
+			if set.DistanceMeters != nil {
+				return models.TrackingDistanceDuration
+			}
+			reps = reps || set.Reps > 0
+			duration = duration || set.DurationSeconds != nil
+		}
+	}
+	if duration && !reps {
+		return models.TrackingDuration
+	}
+	return models.TrackingWeightReps
+}
+
+// importTemplateExercises lists a workout's exercises in the order they were
+// trained, with the number of sets of each
+func importTemplateExercises(workout imports.Workout, exerciseIDs map[string]uint) []CreateTemplateExerciseRequest {
+	var exercises []CreateTemplateExerciseRequest
+	index := make(map[uint]int)
+	for _, set := range workout.Sets {
+		exerciseID := exerciseIDs[importExerciseKey(set.ExerciseName)]
+		if i, ok := index[exerciseID]; ok {
+			exercises[i].Sets++
+			continue
+		}
+		index[exerciseID] = len(exercises)
+		exercises = append(exercises, CreateTemplateExerciseRequest{ExerciseID: exerciseID, Sets: 1})
+	}
+	return exercises
+}
+
+func importWorkoutName(workout imports.Workout) string {
+	if name := strings.TrimSpace(workout.Name); name != "" {
+		return name
+	}
+	return "Imported workout"
+}
+
+// importExerciseKey normalizes an exported exercise name, so names that differ
+// only in case or spacing map to one exercise
+func importExerciseKey(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+func importWorkoutKey(name string, startedAt time.Time) string {
+	return fmt.Sprintf("%s|%d", strings.ToLower(strings.TrimSpace(name)), startedAt.Unix())
+}
@@ -0,0 +1,294 @@
+package controllers
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/rachitnimje/trackle-web/models"
+	"github.com/rachitnimje/trackle-web/utils"
+)
+
+// Where the values of a drafted set come from
+const (
+	draftSourceHistory     = "last_workout"
+	draftSourceProgression = "progression"
+	draftSourceProgram     = "program"
+	draftSourceTemplate    = "template"
+	draftSourceNone        = "none"
+)
+
+// NextWorkoutDraft is a workout prefilled from a template, in the shape of a
+// CreateWorkoutRequest so it can be edited and posted back as is. Weights are
+// in Unit. Sets of grouped template exercises carry their group.
+type NextWorkoutDraft struct {
+	Name       string                  `json:"name"`
+	TemplateID uint                    `json:"template_id"`
+	Notes      string                  `json:"notes"`
+	Unit       string                  `json:"unit"`
+	Entries    []NextWorkoutEntry      `json:"entries"`
+	Exercises  []NextWorkoutExercise   `json:"exercises"`
+	Groups     []ExerciseGroupResponse `json:"groups"`
+}
+
+// NextWorkoutEntry is one drafted set. The extra fields describe where its
+// values came from and are ignored when the draft is posted.
+type NextWorkoutEntry struct {
+	WorkoutEntryRequest
+	ExerciseName string   `json:"exercise_name"`
+	TargetReps   *int     `json:"target_reps"`
+	TargetWeight *float64 `json:"target_weight"`
+	RestSeconds  *int     `json:"rest_seconds"`
+	Source       string   `json:"source"`
+}
+
+// NextWorkoutExercise tells when each exercise of the draft was last trained
+// and what its progression rule prescribed
+type NextWorkoutExercise struct {
+	ExerciseID      uint                     `json:"exercise_id"`
+	ExerciseName    string                   `json:"exercise_name"`
+	TrackingType    string                   `json:"tracking_type"`
+	LastWorkoutID   *uint                    `json:"last_workout_id"`
+	LastPerformedAt *time.Time               `json:"last_performed_at"`
+	Progression     *ProgressionPrescription `json:"progression"`
+}
+
+// exerciseHistory is the sets of an exercise in the last workout it was trained in
+type exerciseHistory struct {
+	WorkoutID   uint
+	PerformedAt time.Time
+	Sets        []models.WorkoutEntry
+}
+
+// GetNextUserWorkout drafts the next workout of a template: its exercises and
+// set counts, prefilled with the reps and weights of the last workout each
+// exercise was trained in. Exercises with a progression rule get the weight
+// and reps it prescribes instead. Exercises never trained fall back to the
+// template's set targets.
+func GetNextUserWorkout(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
+		// extract the user_id from context
+		userID, exists := c.Get("user_id")
+		if !exists {
+			appErr := utils.NewAuthenticationError("User not authenticated", nil)
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
+		// parse the template ID with validation
+		templateID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil || templateID == 0 {
+			appErr := utils.NewInvalidInputError("Invalid template ID", err)
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
+		template, err := loadUserTemplate(db, uint(templateID), userID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				appErr := utils.NewNotFoundError("Template not found", nil)
+				utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			} else {
+				appErr := utils.NewDatabaseError("Failed to fetch template", err)
+				utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			}
+			return
+		}
+
+		preferredUnit, appErr := preferredWeightUnit(db, userID)
+		if appErr != nil {
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
+		exerciseIDs := make([]uint, 0, len(template.Exercises))
+		for _, templateExercise := range template.Exercises {
+			exerciseIDs = append(exerciseIDs, templateExercise.ExerciseID)
+		}
+		history, err := recentExerciseSessions(db, userID, exerciseIDs, historyLimit(template))
+		if err != nil {
+			appErr := utils.NewDatabaseError("Failed to load workout history", err)
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
+		draft := draftNextWorkout(template, history, preferredUnit)
+
+		utils.SuccessResponse(c, "Next workout drafted successfully", draft)
+	}
+}
+
+// historyLimit is how many past workouts of each exercise drafting a template
+// needs: progression rules that deload look back over several workouts
+func historyLimit(template models.Template) int {
+	limit := 1
+	for _, templateExercise := range template.Exercises {
+		if templateExercise.DeloadAfter != nil {
+			limit = max(limit, *templateExercise.DeloadAfter)
+		}
+	}
+	return limit
+}
+
+// recentExerciseSessions loads, for each exercise, the non-warm-up sets of the
+// most recent finished workouts that trained it, up to limit workouts, most
+// recent first
+func recentExerciseSessions(db *gorm.DB, userID interface{}, exerciseIDs []uint, limit int) (map[uint][]exerciseHistory, error) {
+	sessions := make(map[uint][]exerciseHistory)
+	if len(exerciseIDs) == 0 {
+		return sessions, nil
+	}
+
+	ranked := db.Table("workout_entries").
+		Select("workout_entries.exercise_id, workouts.id AS workout_id, workouts.performed_at, "+
+			"DENSE_RANK() OVER (PARTITION BY workout_entries.exercise_id ORDER BY workouts.performed_at DESC, workouts.id DESC) AS recency").
+		Joins("JOIN workouts ON workouts.id = workout_entries.workout_id AND workouts.deleted_at IS NULL").
+		Where("workout_entries.deleted_at IS NULL AND workouts.user_id = ? AND workout_entries.exercise_id IN ?", userID, exerciseIDs).
+		// a session in progress is not a finished workout to repeat
+		Where("workouts.started_at IS NULL OR workouts.finished_at IS NOT NULL").
+		Where("workout_entries.set_type <> ?", models.SetTypeWarmup)
+
+	var recent []struct {
+		ExerciseID  uint
+		WorkoutID   uint
+		PerformedAt time.Time
+	}
+	if err := db.Table("(?) AS ranked", ranked).
+		Select("DISTINCT exercise_id, workout_id, performed_at").
+		Where("recency <= ?", limit).
+		Order("exercise_id, performed_at DESC, workout_id DESC").
+		Scan(&recent).Error; err != nil {
+		return nil, err
+	}
+	if len(recent) == 0 {
+		return sessions, nil
+	}
+
+	type sessionKey struct{ exerciseID, workoutID uint }
+	index := make(map[sessionKey]int, len(recent))
+	pairs := make([][]interface{}, 0, len(recent))
+	for _, row := range recent {
+		index[sessionKey{row.ExerciseID, row.WorkoutID}] = len(sessions[row.ExerciseID])
+		sessions[row.ExerciseID] = append(sessions[row.ExerciseID], exerciseHistory{WorkoutID: row.WorkoutID, PerformedAt: row.PerformedAt})
+		pairs = append(pairs, []interface{}{row.WorkoutID, row.ExerciseID})
+	}
+
+	var entries []models.WorkoutEntry
+	if err := db.Where("(workout_id, exercise_id) IN ? AND set_type <> ?", pairs, models.SetTypeWarmup).
+		Order("set_number, id").
+		Find(&entries).Error; err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		session := &sessions[entry.ExerciseID][index[sessionKey{entry.ExerciseID, entry.WorkoutID}]]
+		session.Sets = append(session.Sets, entry)
+	}
+	return sessions, nil
+}
+
+// draftNextWorkout builds the draft of a template loaded by loadUserTemplate.
+// Each set repeats the set in the same position last time; when the template
+// now has more sets than were done, the extra ones repeat the last set done.
+// Exercises with a progression rule and history take its prescription instead.
+func draftNextWorkout(template models.Template, history map[uint][]exerciseHistory, unit string) NextWorkoutDraft {
+	draft := NextWorkoutDraft{
+		Name:       template.Name,
+		TemplateID: template.ID,
+		Unit:       unit,
+		Entries:    []NextWorkoutEntry{},
+		Exercises:  make([]NextWorkoutExercise, 0, len(template.Exercises)),
+		Groups:     templateGroups(template.Exercises),
+	}
+
+	for _, templateExercise := range template.Exercises {
+		exercise := templateExercise.Exercise
+		sessions := history[templateExercise.ExerciseID]
+
+		summary := NextWorkoutExercise{
+			ExerciseID:   templateExercise.ExerciseID,
+			ExerciseName: exercise.Name,
+			TrackingType: exercise.TrackingType,
+		}
+		var last exerciseHistory
+		if len(sessions) > 0 {
+			last = sessions[0]
+			workoutID, performedAt := last.WorkoutID, last.PerformedAt
+			summary.LastWorkoutID = &workoutID
+			summary.LastPerformedAt = &performedAt
+		}
+
+		// a prescription only replaces the history once there is history to progress from
+		prescription := prescribeProgression(templateExercise, sessions)
+		if prescription != nil {
+			prescribed := *prescription
+			prescribed.Weight = utils.DisplayWeight(prescription.Weight, unit)
+			summary.Progression = &prescribed
+			if prescription.Outcome == progressionStart {
+				prescription = nil
+			}
+		}
+		draft.Exercises = append(draft.Exercises, summary)
+
+		targets := make(map[int]models.TemplateSet, len(templateExercise.SetTargets))
+		for _, target := range templateExercise.SetTargets {
+			targets[target.SetNumber] = target
+		}
+
+		for setNumber := 1; setNumber <= templateExercise.Sets; setNumber++ {
+			entry := NextWorkoutEntry{
+				WorkoutEntryRequest: WorkoutEntryRequest{
+					ExerciseID: templateExercise.ExerciseID,
+					SetNumber:  setNumber,
+					SetType:    models.SetTypeWorking,
+					GroupID:    templateExercise.GroupID,
+				},
+				ExerciseName: exercise.Name,
+				Source:       draftSourceNone,
+			}
+			if templateExercise.GroupType != nil {
+				entry.GroupType = *templateExercise.GroupType
+			}
+
+			target, hasTarget := targets[setNumber]
+			if hasTarget {
+				entry.TargetReps = target.TargetReps
+				entry.RestSeconds = target.RestSeconds
+				if target.TargetWeight != nil {
+					weight := utils.DisplayWeight(*target.TargetWeight, unit)
+					entry.TargetWeight = &weight
+				}
+			}
+
+			if prescription != nil {
+				entry.Reps = prescription.Reps[setNumber-1]
+				entry.Weight = utils.DisplayWeight(prescription.Weight, unit)
+				entry.Source = draftSourceProgression
+			} else if len(last.Sets) > 0 {
+				previous := last.Sets[min(setNumber, len(last.Sets))-1]
+				entry.SetType = previous.SetType
+				entry.Reps = previous.Reps
+				entry.Weight = utils.DisplayWeight(previous.Weight, unit)
+				entry.DurationSeconds = previous.DurationSeconds
+				entry.DistanceMeters = previous.DistanceMeters
+				entry.Source = draftSourceHistory
+			} else if hasTarget && (target.TargetReps != nil || target.TargetWeight != nil) {
+				if target.TargetReps != nil {
+					entry.Reps = *target.TargetReps
+				}
+				if entry.TargetWeight != nil {
+					entry.Weight = *entry.TargetWeight
+				}
+				entry.Source = draftSourceTemplate
+			}
+
+			draft.Entries = append(draft.Entries, entry)
+		}
+	}
+
+	return draft
+}
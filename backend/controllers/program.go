@@ -0,0 +1,1254 @@
+package controllers
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/rachitnimje/trackle-web/models"
+	"github.com/rachitnimje/trackle-web/utils"
+)
+
+// CreateProgramRequest creates a program. Weeks, and the days of each week, are
+// numbered in request order.
+type CreateProgramRequest struct {
+	Name        string               `json:"name" binding:"required"`
+	Description string               `json:"description"`
+	Weeks       []ProgramWeekRequest `json:"weeks" binding:"required,min=1,dive"`
+}
+
+type UpdateProgramRequest = CreateProgramRequest
+
+type ProgramWeekRequest struct {
+	Name string              `json:"name"`
+	Days []ProgramDayRequest `json:"days" binding:"required,min=1,dive"`
+}
+
+// ProgramDayRequest is one day of a program week. A day without a template is
+// a rest day. Sets load exercises of the day's template as a percentage of the
+// training max and are numbered per exercise in request order.
+type ProgramDayRequest struct {
+	Name       string              `json:"name"`
+	TemplateID *uint               `json:"template_id" binding:"omitempty,min=1"`
+	Sets       []ProgramSetRequest `json:"sets" binding:"omitempty,dive"`
+}
+
+type ProgramSetRequest struct {
+	ExerciseID uint    `json:"exercise_id" binding:"required"`
+	Percent    float64 `json:"percent" binding:"required,gt=0,lte=150"`
+	Reps       int     `json:"reps" binding:"required,min=1"`
+	AMRAP      bool    `json:"amrap"`
+}
+
+// EnrollProgramRequest starts a program. Every exercise the program loads by
+// percentage needs a training max, in Unit or in the user's preferred unit
+// when it is omitted.
+type EnrollProgramRequest struct {
+	Unit          string               `json:"unit" binding:"omitempty,oneof=kg lb"`
+	TrainingMaxes []TrainingMaxRequest `json:"training_maxes" binding:"omitempty,dive"`
+}
+
+// UpdateTrainingMaxesRequest changes training maxes of the active program.
+// Exercises that are left out keep theirs.
+type UpdateTrainingMaxesRequest struct {
+	Unit          string               `json:"unit" binding:"omitempty,oneof=kg lb"`
+	TrainingMaxes []TrainingMaxRequest `json:"training_maxes" binding:"required,min=1,dive"`
+}
+
+type TrainingMaxRequest struct {
+	ExerciseID uint    `json:"exercise_id" binding:"required"`
+	Weight     float64 `json:"weight" binding:"required,gt=0"`
+}
+
+type GetAllProgramsResponse struct {
+	ID          string `json:"id"`
+	CreatedAt   string `json:"created_at"`
+	UpdatedAt   string `json:"updated_at"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Weeks       int    `json:"weeks"`
+}
+
+type ProgramResponse struct {
+	ID          string                `json:"id"`
+	CreatedAt   string                `json:"created_at"`
+	UpdatedAt   string                `json:"updated_at"`
+	Name        string                `json:"name"`
+	Description string                `json:"description"`
+	UserID      uint                  `json:"user_id"`
+	Weeks       []ProgramWeekResponse `json:"weeks"`
+}
+
+type ProgramWeekResponse struct {
+	WeekNumber int                  `json:"week_number"`
+	Name       string               `json:"name"`
+	Days       []ProgramDayResponse `json:"days"`
+}
+
+type ProgramDayResponse struct {
+	DayNumber    int                  `json:"day_number"`
+	Name         string               `json:"name"`
+	RestDay      bool                 `json:"rest_day"`
+	TemplateID   *uint                `json:"template_id"`
+	TemplateName string               `json:"template_name"`
+	Sets         []ProgramSetResponse `json:"sets"`
+}
+
+type ProgramSetResponse struct {
+	ExerciseID   uint    `json:"exercise_id"`
+	ExerciseName string  `json:"exercise_name"`
+	SetNumber    int     `json:"set_number"`
+	Percent      float64 `json:"percent"`
+	Reps         int     `json:"reps"`
+	AMRAP        bool    `json:"amrap"`
+}
+
+// ProgramEnrollmentResponse is a user's run of a program. Training maxes are in
+// WeightUnit.
+type ProgramEnrollmentResponse struct {
+	ID            uint                  `json:"id"`
+	ProgramID     uint                  `json:"program_id"`
+	ProgramName   string                `json:"program_name"`
+	CurrentWeek   int                   `json:"current_week"`
+	CurrentDay    int                   `json:"current_day"`
+	StartedAt     time.Time             `json:"started_at"`
+	CompletedAt   *time.Time            `json:"completed_at"`
+	WeightUnit    string                `json:"weight_unit"`
+	TrainingMaxes []TrainingMaxResponse `json:"training_maxes"`
+}
+
+type TrainingMaxResponse struct {
+	ExerciseID   uint    `json:"exercise_id"`
+	ExerciseName string  `json:"exercise_name"`
+	Weight       float64 `json:"weight"`
+}
+
+// ProgramSessionResponse is the session a user's program is at. Workout is the
+// day's template drafted as the next workout, with the program's sets in place
+// of the template's; it is nil on rest days.
+type ProgramSessionResponse struct {
+	ProgramID   uint              `json:"program_id"`
+	ProgramName string            `json:"program_name"`
+	Week        int               `json:"week"`
+	WeekName    string            `json:"week_name"`
+	Day         int               `json:"day"`
+	DayName     string            `json:"day_name"`
+	TotalWeeks  int               `json:"total_weeks"`
+	RestDay     bool              `json:"rest_day"`
+	Workout     *NextWorkoutDraft `json:"workout"`
+}
+
+func CreateUserProgram(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
+		// extract the user_id from context
+		userID, exists := c.Get("user_id")
+		if !exists {
+			appErr := utils.NewAuthenticationError("User not authenticated", nil)
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
+		var req CreateProgramRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			appErr := utils.NewValidationError("Invalid request data", err)
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
+		if appErr := validateProgramWeeks(db, userID, req.Weeks); appErr != nil {
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
+		var createdProgramID uint
+		var appErr *utils.AppError
+		err := utils.TransactionManager(db, c, func(tx *gorm.DB) error {
+			program := models.Program{
+				Name:        req.Name,
+				Description: req.Description,
+				UserID:      userID.(uint),
+				Weeks:       toProgramWeeks(req.Weeks),
+			}
+
+			// the weeks, days and sets are created with the program
+			if err := tx.Create(&program).Error; err != nil {
+				appErr = utils.NewDatabaseError("Failed to create program", err)
+				return appErr
+			}
+
+			createdProgramID = program.ID
+			return nil
+		})
+
+		if appErr != nil {
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+		if err != nil {
+			// the failed commit has been written
+			return
+		}
+
+		program, err := loadUserProgram(db, createdProgramID, userID)
+		if err != nil {
+			appErr := utils.NewDatabaseError("Failed to load program", err)
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
+		utils.CreatedResponse(c, "Program created successfully", toProgramResponse(program))
+	}
+}
+
+func GetAllUserPrograms(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
+		// extract the user_id from context
+		userID, exists := c.Get("user_id")
+		if !exists {
+			appErr := utils.NewAuthenticationError("User not authenticated", nil)
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
+		// Parse pagination parameters
+		page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+		if err != nil || page < 1 {
+			page = 1
+		}
+
+		limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
+		if err != nil || limit < 1 || limit > 100 {
+			limit = 10
+		}
+
+		offset := (page - 1) * limit
+
+		query := db.Model(&models.Program{}).Where("user_id = ?", userID)
+		if search := c.Query("search"); search != "" {
+			query = query.Where("name ILIKE ? OR description ILIKE ?", "%"+search+"%", "%"+search+"%")
+		}
+
+		var total int64
+		if err := query.Count(&total).Error; err != nil {
+			appErr := utils.NewDatabaseError("Failed to count programs", err)
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
+		var programs []models.Program
+		if err := query.Preload("Weeks").
+			Order("created_at DESC").
+			Offset(offset).
+			Limit(limit).
+			Find(&programs).Error; err != nil {
+			appErr := utils.NewDatabaseError("Failed to fetch programs", err)
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
+		response := make([]GetAllProgramsResponse, 0, len(programs))
+		for _, program := range programs {
+			response = append(response, GetAllProgramsResponse{
+				ID:          strconv.Itoa(int(program.ID)),
+				CreatedAt:   program.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
+				UpdatedAt:   program.UpdatedAt.Format("2006-01-02T15:04:05Z07:00"),
+				Name:        program.Name,
+				Description: program.Description,
+				Weeks:       len(program.Weeks),
+			})
+		}
+
+		utils.PaginatedResponse(c, "Programs retrieved successfully", response, page, limit, total)
+	}
+}
+
+func GetUserProgram(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
+		// extract the user_id from context
+		userID, exists := c.Get("user_id")
+		if !exists {
+			appErr := utils.NewAuthenticationError("User not authenticated", nil)
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
+		programID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil || programID == 0 {
+			appErr := utils.NewInvalidInputError("Invalid program ID", err)
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
+		program, err := loadUserProgram(db, uint(programID), userID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				appErr := utils.NewNotFoundError("Program not found", nil)
+				utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			} else {
+				appErr := utils.NewDatabaseError("Failed to fetch program", err)
+				utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			}
+			return
+		}
+
+		utils.SuccessResponse(c, "Program retrieved successfully", toProgramResponse(program))
+	}
+}
+
+// UpdateUserProgram replaces a program's weeks. Users running the program keep
+// their position, which now points into the new weeks.
+func UpdateUserProgram(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
+		// extract the user_id from context
+		userID, exists := c.Get("user_id")
+		if !exists {
+			appErr := utils.NewAuthenticationError("User not authenticated", nil)
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
+		programID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil || programID == 0 {
+			appErr := utils.NewInvalidInputError("Invalid program ID", err)
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
+		var req UpdateProgramRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			appErr := utils.NewValidationError("Invalid request data", err)
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
+		var program models.Program
+		if err := db.Where("id = ? AND user_id = ?", programID, userID).First(&program).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				appErr := utils.NewNotFoundError("Program not found", nil)
+				utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			} else {
+				appErr := utils.NewDatabaseError("Failed to find program", err)
+				utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			}
+			return
+		}
+
+		if appErr := validateProgramWeeks(db, userID, req.Weeks); appErr != nil {
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
+		var appErr *utils.AppError
+		err = utils.TransactionManager(db, c, func(tx *gorm.DB) error {
+			program.Name = req.Name
+			program.Description = req.Description
+			if err := tx.Omit("Weeks").Save(&program).Error; err != nil {
+				appErr = utils.NewDatabaseError("Failed to update program", err)
+				return appErr
+			}
+
+			if err := deleteProgramWeeks(tx.Unscoped(), program.ID); err != nil {
+				appErr = utils.NewDatabaseError("Failed to replace program weeks", err)
+				return appErr
+			}
+
+			weeks := toProgramWeeks(req.Weeks)
+			for i := range weeks {
+				weeks[i].ProgramID = program.ID
+			}
+			if err := tx.Create(&weeks).Error; err != nil {
+				appErr = utils.NewDatabaseError("Failed to create program weeks", err)
+				return appErr
+			}
+
+			return nil
+		})
+
+		if appErr != nil {
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+		if err != nil {
+			// the failed commit has been written
+			return
+		}
+
+		program, err = loadUserProgram(db, program.ID, userID)
+		if err != nil {
+			appErr := utils.NewDatabaseError("Failed to load program", err)
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
+		utils.SuccessResponse(c, "Program updated successfully", toProgramResponse(program))
+	}
+}
+
+// DeleteUserProgram deletes a program and ends every run of it in progress
+func DeleteUserProgram(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
+		// extract the user_id from context
+		userID, exists := c.Get("user_id")
+		if !exists {
+			appErr := utils.NewAuthenticationError("User not authenticated", nil)
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
+		programID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil || programID == 0 {
+			appErr := utils.NewInvalidInputError("Invalid program ID", err)
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
+		var program models.Program
+		if err := db.Where("id = ? AND user_id = ?", programID, userID).First(&program).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				appErr := utils.NewNotFoundError("Program not found", nil)
+				utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			} else {
+				appErr := utils.NewDatabaseError("Failed to find program", err)
+				utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			}
+			return
+		}
+
+		var appErr *utils.AppError
+		err = utils.TransactionManager(db, c, func(tx *gorm.DB) error {
+			if err := tx.Model(&models.ProgramEnrollment{}).
+				Where("program_id = ? AND completed_at IS NULL", program.ID).
+				Update("completed_at", time.Now()).Error; err != nil {
+				appErr = utils.NewDatabaseError("Failed to end program enrollments", err)
+				return appErr
+			}
+
+			if err := deleteProgramWeeks(tx, program.ID); err != nil {
+				appErr = utils.NewDatabaseError("Failed to delete program weeks", err)
+				return appErr
+			}
+
+			if err := tx.Delete(&program).Error; err != nil {
+				appErr = utils.NewDatabaseError("Failed to delete program", err)
+				return appErr
+			}
+
+			return nil
+		})
+
+		if appErr != nil {
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+		if err != nil {
+			// the failed commit has been written
+			return
+		}
+
+		utils.SuccessResponse(c, "Program deleted successfully", nil)
+	}
+}
+
+// EnrollUserProgram starts a program at its first day. A user runs one program
+// at a time.
+func EnrollUserProgram(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
+		// extract the user_id from context
+		userID, exists := c.Get("user_id")
+		if !exists {
+			appErr := utils.NewAuthenticationError("User not authenticated", nil)
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
+		programID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil || programID == 0 {
+			appErr := utils.NewInvalidInputError("Invalid program ID", err)
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
+		var req EnrollProgramRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			appErr := utils.NewValidationError("Invalid request data", err)
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
+		program, err := loadUserProgram(db, uint(programID), userID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				appErr := utils.NewNotFoundError("Program not found", nil)
+				utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			} else {
+				appErr := utils.NewDatabaseError("Failed to fetch program", err)
+				utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			}
+			return
+		}
+
+		// every percentage-loaded exercise needs a training max, and only those
+		loaded := programExercises(program)
+		given := make(map[uint]bool, len(req.TrainingMaxes))
+		for _, trainingMax := range req.TrainingMaxes {
+			if given[trainingMax.ExerciseID] {
+				appErr := utils.NewInvalidInputError("Duplicate training max exercise IDs not allowed", nil)
+				utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+				return
+			}
+			if _, ok := loaded[trainingMax.ExerciseID]; !ok {
+				appErr := utils.NewInvalidInputError("Training max given for an exercise the program does not load by percentage", nil)
+				utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+				return
+			}
+			given[trainingMax.ExerciseID] = true
+		}
+		for exerciseID, name := range loaded {
+			if !given[exerciseID] {
+				appErr := utils.NewInvalidInputError("Training max for "+name+" is required", nil)
+				utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+				return
+			}
+		}
+
+		preferredUnit, appErr := preferredWeightUnit(db, userID)
+		if appErr != nil {
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+		inputUnit := requestWeightUnit(req.Unit, preferredUnit)
+
+		var enrollmentID uint
+		err = utils.TransactionManager(db, c, func(tx *gorm.DB) error {
+			var activeCount int64
+			if err := activeEnrollments(tx, userID).Count(&activeCount).Error; err != nil {
+				appErr = utils.NewDatabaseError("Failed to check for an active program", err)
+				return appErr
+			}
+			if activeCount > 0 {
+				appErr = utils.NewDuplicateEntryError("A program is already in progress", nil)
+				return appErr
+			}
+
+			enrollment := models.ProgramEnrollment{
+				UserID:      userID.(uint),
+				ProgramID:   program.ID,
+				CurrentWeek: 1,
+				CurrentDay:  1,
+				StartedAt:   time.Now(),
+			}
+			for _, trainingMax := range req.TrainingMaxes {
+				enrollment.TrainingMaxes = append(enrollment.TrainingMaxes, models.ProgramTrainingMax{
+					ExerciseID: trainingMax.ExerciseID,
+					Weight:     utils.ToKg(trainingMax.Weight, inputUnit),
+				})
+			}
+
+			// the active enrollment index catches a concurrent enrollment
+			if err := tx.Create(&enrollment).Error; err != nil {
+				if utils.IsUniqueViolation(err) {
+					appErr = utils.NewDuplicateEntryError("A program is already in progress", nil)
+				} else {
+					appErr = utils.NewDatabaseError("Failed to enroll in program", err)
+				}
+				return appErr
+			}
+
+			enrollmentID = enrollment.ID
+			return nil
+		})
+
+		if appErr != nil {
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+		if err != nil {
+			// the failed commit has been written
+			return
+		}
+
+		var enrollment models.ProgramEnrollment
+		if err := preloadEnrollment(db).First(&enrollment, enrollmentID).Error; err != nil {
+			appErr := utils.NewDatabaseError("Failed to load program enrollment", err)
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
+		utils.CreatedResponse(c, "Enrolled in program successfully", toEnrollmentResponse(enrollment, preferredUnit))
+	}
+}
+
+// GetUserProgramEnrollment returns the program the user is running
+func GetUserProgramEnrollment(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
+		// extract the user_id from context
+		userID, exists := c.Get("user_id")
+		if !exists {
+			appErr := utils.NewAuthenticationError("User not authenticated", nil)
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
+		enrollment, appErr := loadActiveEnrollment(db, userID)
+		if appErr != nil {
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
+		preferredUnit, appErr := preferredWeightUnit(db, userID)
+		if appErr != nil {
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
+		utils.SuccessResponse(c, "Program enrollment retrieved successfully", toEnrollmentResponse(enrollment, preferredUnit))
+	}
+}
+
+// GetTodayProgramSession tells the user what their program has them do next.
+// Training days come with the day's template drafted as a workout, its
+// percentage-based sets loaded from the user's training maxes.
+func GetTodayProgramSession(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
+		// extract the user_id from context
+		userID, exists := c.Get("user_id")
+		if !exists {
+			appErr := utils.NewAuthenticationError("User not authenticated", nil)
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
+		enrollment, appErr := loadActiveEnrollment(db, userID)
+		if appErr != nil {
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
+		program, err := loadUserProgram(db, enrollment.ProgramID, userID)
+		if err != nil {
+			appErr := utils.NewDatabaseError("Failed to fetch program", err)
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
+		week, day, ok := programPosition(program, enrollment.CurrentWeek, enrollment.CurrentDay)
+		if !ok {
+			appErr := utils.NewNotFoundError("The program has no sessions left; advance it to finish", nil)
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
+		response := ProgramSessionResponse{
+			ProgramID:   program.ID,
+			ProgramName: program.Name,
+			Week:        week.WeekNumber,
+			WeekName:    week.Name,
+			Day:         day.DayNumber,
+			DayName:     day.Name,
+			TotalWeeks:  len(program.Weeks),
+			RestDay:     day.TemplateID == nil,
+		}
+		if response.RestDay {
+			utils.SuccessResponse(c, "Today's session retrieved successfully", response)
+			return
+		}
+
+		template, err := loadUserTemplate(db, *day.TemplateID, userID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				appErr := utils.NewNotFoundError("The template of today's session no longer exists", nil)
+				utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			} else {
+				appErr := utils.NewDatabaseError("Failed to fetch template", err)
+				utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			}
+			return
+		}
+
+		preferredUnit, appErr := preferredWeightUnit(db, userID)
+		if appErr != nil {
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
+		exerciseIDs := make([]uint, 0, len(template.Exercises))
+		for _, templateExercise := range template.Exercises {
+			exerciseIDs = append(exerciseIDs, templateExercise.ExerciseID)
+		}
+		history, err := recentExerciseSessions(db, userID, exerciseIDs, historyLimit(template))
+		if err != nil {
+			appErr := utils.NewDatabaseError("Failed to load workout history", err)
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
+		draft := draftNextWorkout(template, history, preferredUnit)
+		applyProgramSets(&draft, day.Sets, enrollment.TrainingMaxes, preferredUnit)
+		response.Workout = &draft
+
+		utils.SuccessResponse(c, "Today's session retrieved successfully", response)
+	}
+}
+
+// AdvanceUserProgram moves the user's program on to its next day, whether the
+// current one was trained or skipped. Advancing past the last day completes the
+// program.
+func AdvanceUserProgram(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
+		// extract the user_id from context
+		userID, exists := c.Get("user_id")
+		if !exists {
+			appErr := utils.NewAuthenticationError("User not authenticated", nil)
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
+		enrollment, appErr := loadActiveEnrollment(db, userID)
+		if appErr != nil {
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
+		program, err := loadUserProgram(db, enrollment.ProgramID, userID)
+		if err != nil {
+			appErr := utils.NewDatabaseError("Failed to fetch program", err)
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
+		readWeek, readDay := enrollment.CurrentWeek, enrollment.CurrentDay
+		week, day, ok := nextProgramPosition(program, readWeek, readDay)
+		if ok {
+			enrollment.CurrentWeek = week
+			enrollment.CurrentDay = day
+		} else {
+			now := time.Now()
+			enrollment.CompletedAt = &now
+		}
+
+		// only move on from the position that was read, so a concurrent advance
+		// is not overwritten
+		result := db.Model(&models.ProgramEnrollment{}).
+			Where("id = ? AND current_week = ? AND current_day = ? AND completed_at IS NULL", enrollment.ID, readWeek, readDay).
+			Updates(map[string]interface{}{
+				"current_week": enrollment.CurrentWeek,
+				"current_day":  enrollment.CurrentDay,
+				"completed_at": enrollment.CompletedAt,
+			})
+		if result.Error != nil {
+			appErr := utils.NewDatabaseError("Failed to advance program", result.Error)
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+		if result.RowsAffected == 0 {
+			appErr := utils.NewDuplicateEntryError("The program has already been advanced", nil)
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
+		preferredUnit, appErr := preferredWeightUnit(db, userID)
+		if appErr != nil {
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
+		if !ok {
+			utils.SuccessResponse(c, "Program completed successfully", toEnrollmentResponse(enrollment, preferredUnit))
+			return
+		}
+		utils.SuccessResponse(c, "Program advanced successfully", toEnrollmentResponse(enrollment, preferredUnit))
+	}
+}
+
+// UpdateProgramTrainingMaxes sets training maxes of the user's program, which
+// reloads every session that follows
+func UpdateProgramTrainingMaxes(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
+		// extract the user_id from context
+		userID, exists := c.Get("user_id")
+		if !exists {
+			appErr := utils.NewAuthenticationError("User not authenticated", nil)
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
+		var req UpdateTrainingMaxesRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			appErr := utils.NewValidationError("Invalid request data", err)
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
+		enrollment, appErr := loadActiveEnrollment(db, userID)
+		if appErr != nil {
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
+		program, err := loadUserProgram(db, enrollment.ProgramID, userID)
+		if err != nil {
+			appErr := utils.NewDatabaseError("Failed to fetch program", err)
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
+		loaded := programExercises(program)
+		for _, trainingMax := range req.TrainingMaxes {
+			if _, ok := loaded[trainingMax.ExerciseID]; !ok {
+				appErr := utils.NewInvalidInputError("Training max given for an exercise the program does not load by percentage", nil)
+				utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+				return
+			}
+		}
+
+		preferredUnit, appErr := preferredWeightUnit(db, userID)
+		if appErr != nil {
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+		inputUnit := requestWeightUnit(req.Unit, preferredUnit)
+
+		existing := make(map[uint]models.ProgramTrainingMax, len(enrollment.TrainingMaxes))
+		for _, trainingMax := range enrollment.TrainingMaxes {
+			existing[trainingMax.ExerciseID] = trainingMax
+		}
+
+		err = utils.TransactionManager(db, c, func(tx *gorm.DB) error {
+			for _, given := range req.TrainingMaxes {
+				trainingMax, found := existing[given.ExerciseID]
+				if !found {
+					trainingMax = models.ProgramTrainingMax{
+						ProgramEnrollmentID: enrollment.ID,
+						ExerciseID:          given.ExerciseID,
+					}
+				}
+				trainingMax.Weight = utils.ToKg(given.Weight, inputUnit)
+
+				if err := tx.Omit("Exercise").Save(&trainingMax).Error; err != nil {
+					appErr = utils.NewDatabaseError("Failed to save training max", err)
+					return appErr
+				}
+			}
+
+			return nil
+		})
+
+		if appErr != nil {
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+		if err != nil {
+			// the failed commit has been written
+			return
+		}
+
+		enrollment, appErr = loadActiveEnrollment(db, userID)
+		if appErr != nil {
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
+		utils.SuccessResponse(c, "Training maxes updated successfully", toEnrollmentResponse(enrollment, preferredUnit))
+	}
+}
+
+// LeaveUserProgram ends the user's program where it is
+func LeaveUserProgram(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
+		// extract the user_id from context
+		userID, exists := c.Get("user_id")
+		if !exists {
+			appErr := utils.NewAuthenticationError("User not authenticated", nil)
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
+		enrollment, appErr := loadActiveEnrollment(db, userID)
+		if appErr != nil {
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
+		if err := db.Model(&enrollment).Update("completed_at", time.Now()).Error; err != nil {
+			appErr := utils.NewDatabaseError("Failed to leave program", err)
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
+		utils.SuccessResponse(c, "Program left successfully", nil)
+	}
+}
+
+// validateProgramWeeks checks that each day's template belongs to the user and
+// that its sets load weight and reps exercises of that template
+func validateProgramWeeks(db *gorm.DB, userID interface{}, weeks []ProgramWeekRequest) *utils.AppError {
+	templateIDMap := make(map[uint]bool)
+	var templateIDs []uint
+	for _, week := range weeks {
+		for _, day := range week.Days {
+			if day.TemplateID == nil {
+				if len(day.Sets) > 0 {
+					return utils.NewInvalidInputError("A rest day cannot have sets", nil)
+				}
+				continue
+			}
+			if !templateIDMap[*day.TemplateID] {
+				templateIDMap[*day.TemplateID] = true
+				templateIDs = append(templateIDs, *day.TemplateID)
+			}
+		}
+	}
+	if len(templateIDs) == 0 {
+		return nil
+	}
+
+	var templates []models.Template
+	if err := db.Where("id IN ? AND user_id = ?", templateIDs, userID).
+		Preload("Exercises.Exercise").
+		Find(&templates).Error; err != nil {
+		return utils.NewDatabaseError("Failed to verify templates", err)
+	}
+	if len(templates) != len(templateIDs) {
+		return utils.NewInvalidInputError("One or more template IDs are invalid", nil)
+	}
+
+	templateExercises := make(map[uint]map[uint]models.Exercise, len(templates))
+	for _, template := range templates {
+		exercises := make(map[uint]models.Exercise, len(template.Exercises))
+		for _, templateExercise := range template.Exercises {
+			exercises[templateExercise.ExerciseID] = templateExercise.Exercise
+		}
+		templateExercises[template.ID] = exercises
+	}
+
+	for _, week := range weeks {
+		for _, day := range week.Days {
+			if day.TemplateID == nil {
+				continue
+			}
+			for _, set := range day.Sets {
+				exercise, ok := templateExercises[*day.TemplateID][set.ExerciseID]
+				if !ok {
+					return utils.NewInvalidInputError("Program sets must be of exercises in the day's template", nil)
+				}
+				if exercise.TrackingType != models.TrackingWeightReps {
+					return utils.NewInvalidInputError(exercise.Name+" is not tracked by weight and reps, so it cannot be loaded by percentage", nil)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// toProgramWeeks builds the weeks of a program, numbering weeks and days in
+// request order and sets per exercise
+func toProgramWeeks(weeks []ProgramWeekRequest) []models.ProgramWeek {
+	programWeeks := make([]models.ProgramWeek, len(weeks))
+	for i, week := range weeks {
+		programWeeks[i] = models.ProgramWeek{
+			WeekNumber: i + 1,
+			Name:       week.Name,
+			Days:       make([]models.ProgramDay, len(week.Days)),
+		}
+		for j, day := range week.Days {
+			programDay := models.ProgramDay{
+				DayNumber:  j + 1,
+				Name:       day.Name,
+				TemplateID: day.TemplateID,
+			}
+			setNumbers := make(map[uint]int)
+			for _, set := range day.Sets {
+				setNumbers[set.ExerciseID]++
+				programDay.Sets = append(programDay.Sets, models.ProgramSet{
+					ExerciseID: set.ExerciseID,
+					SetNumber:  setNumbers[set.ExerciseID],
+					Percent:    set.Percent,
+					Reps:       set.Reps,
+					AMRAP:      set.AMRAP,
+				})
+			}
+			programWeeks[i].Days[j] = programDay
+		}
+	}
+	return programWeeks
+}
+
+// deleteProgramWeeks deletes a program's weeks with their days and sets
+func deleteProgramWeeks(tx *gorm.DB, programID uint) error {
+	weekIDs := tx.Model(&models.ProgramWeek{}).Select("id").Where("program_id = ?", programID)
+	dayIDs := tx.Model(&models.ProgramDay{}).Select("id").Where("program_week_id IN (?)", weekIDs)
+
+	if err := tx.Where("program_day_id IN (?)", dayIDs).Delete(&models.ProgramSet{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("program_week_id IN (?)", weekIDs).Delete(&models.ProgramDay{}).Error; err != nil {
+		return err
+	}
+	return tx.Where("program_id = ?", programID).Delete(&models.ProgramWeek{}).Error
+}
+
+// loadUserProgram loads a user's program with its weeks, days and sets in order
+func loadUserProgram(db *gorm.DB, programID uint, userID interface{}) (models.Program, error) {
+	var program models.Program
+	err := db.Where("id = ? AND user_id = ?", programID, userID).
+		Preload("Weeks", func(db *gorm.DB) *gorm.DB {
+			return db.Order("week_number")
+		}).
+		Preload("Weeks.Days", func(db *gorm.DB) *gorm.DB {
+			return db.Order("day_number")
+		}).
+		Preload("Weeks.Days.Template").
+		Preload("Weeks.Days.Sets", func(db *gorm.DB) *gorm.DB {
+			return db.Order("id")
+		}).
+		Preload("Weeks.Days.Sets.Exercise").
+		First(&program).Error
+	return program, err
+}
+
+// programExercises returns the names of the exercises a program loaded by
+// loadUserProgram loads by percentage, by ID
+func programExercises(program models.Program) map[uint]string {
+	exercises := make(map[uint]string)
+	for _, week := range program.Weeks {
+		for _, day := range week.Days {
+			for _, set := range day.Sets {
+				exercises[set.ExerciseID] = set.Exercise.Name
+			}
+		}
+	}
+	return exercises
+}
+
+// programPosition finds a week and day of a program loaded by loadUserProgram
+func programPosition(program models.Program, weekNumber, dayNumber int) (models.ProgramWeek, models.ProgramDay, bool) {
+	for _, week := range program.Weeks {
+		if week.WeekNumber != weekNumber {
+			continue
+		}
+		for _, day := range week.Days {
+			if day.DayNumber == dayNumber {
+				return week, day, true
+			}
+		}
+	}
+	return models.ProgramWeek{}, models.ProgramDay{}, false
+}
+
+// nextProgramPosition returns the week and day after the given one, or false
+// when it was the program's last day
+func nextProgramPosition(program models.Program, weekNumber, dayNumber int) (int, int, bool) {
+	for _, week := range program.Weeks {
+		if week.WeekNumber < weekNumber {
+			continue
+		}
+		for _, day := range week.Days {
+			if week.WeekNumber > weekNumber || day.DayNumber > dayNumber {
+				return week.WeekNumber, day.DayNumber, true
+			}
+		}
+	}
+	return 0, 0, false
+}
+
+// applyProgramSets replaces the drafted sets of each exercise the program day
+// loads by percentage with the day's sets, weighed from the training maxes.
+// Exercises without a training max keep their drafted sets.
+func applyProgramSets(draft *NextWorkoutDraft, sets []models.ProgramSet, trainingMaxes []models.ProgramTrainingMax, unit string) {
+	maxes := make(map[uint]float64, len(trainingMaxes))
+	for _, trainingMax := range trainingMaxes {
+		maxes[trainingMax.ExerciseID] = trainingMax.Weight
+	}
+
+	byExercise := make(map[uint][]models.ProgramSet)
+	for _, set := range sets {
+		if _, ok := maxes[set.ExerciseID]; ok {
+			byExercise[set.ExerciseID] = append(byExercise[set.ExerciseID], set)
+		}
+	}
+	if len(byExercise) == 0 {
+		return
+	}
+
+	// keep the template's rest periods for the sets that replace its own
+	type setKey struct {
+		exerciseID uint
+		setNumber  int
+	}
+	restSeconds := make(map[setKey]*int)
+	for _, entry := range draft.Entries {
+		restSeconds[setKey{entry.ExerciseID, entry.SetNumber}] = entry.RestSeconds
+	}
+
+	entries := make([]NextWorkoutEntry, 0, len(draft.Entries))
+	placed := make(map[uint]bool)
+	for _, entry := range draft.Entries {
+		programSets, ok := byExercise[entry.ExerciseID]
+		if !ok {
+			entries = append(entries, entry)
+			continue
+		}
+		if placed[entry.ExerciseID] {
+			continue
+		}
+		placed[entry.ExerciseID] = true
+
+		for _, set := range programSets {
+			setType := models.SetTypeWorking
+			if set.AMRAP {
+				setType = models.SetTypeAMRAP
+			}
+			reps := set.Reps
+			weight := utils.DisplayWeight(maxes[set.ExerciseID]*set.Percent/100, unit)
+
+			entries = append(entries, NextWorkoutEntry{
+				WorkoutEntryRequest: WorkoutEntryRequest{
+					ExerciseID: set.ExerciseID,
+					SetNumber:  set.SetNumber,
+					SetType:    setType,
+					Reps:       reps,
+					Weight:     weight,
+					GroupID:    entry.GroupID,
+					GroupType:  entry.GroupType,
+				},
+				ExerciseName: entry.ExerciseName,
+				TargetReps:   &reps,
+				TargetWeight: &weight,
+				RestSeconds:  restSeconds[setKey{set.ExerciseID, set.SetNumber}],
+				Source:       draftSourceProgram,
+			})
+		}
+	}
+	draft.Entries = entries
+
+	// the program's loading takes the place of the template's progression rules
+	for i, exercise := range draft.Exercises {
+		if _, ok := byExercise[exercise.ExerciseID]; ok {
+			draft.Exercises[i].Progression = nil
+		}
+	}
+}
+
+// activeEnrollments scopes an enrollment query to the user's program in progress
+func activeEnrollments(db *gorm.DB, userID interface{}) *gorm.DB {
+	return db.Model(&models.ProgramEnrollment{}).Where("user_id = ? AND completed_at IS NULL", userID)
+}
+
+func preloadEnrollment(db *gorm.DB) *gorm.DB {
+	return db.Preload("Program").
+		Preload("TrainingMaxes", func(db *gorm.DB) *gorm.DB {
+			return db.Order("id")
+		}).
+		Preload("TrainingMaxes.Exercise")
+}
+
+// loadActiveEnrollment loads the user's program in progress with its training maxes
+func loadActiveEnrollment(db *gorm.DB, userID interface{}) (models.ProgramEnrollment, *utils.AppError) {
+	var enrollment models.ProgramEnrollment
+	if err := preloadEnrollment(activeEnrollments(db, userID)).First(&enrollment).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return enrollment, utils.NewNotFoundError("No program in progress", nil)
+		}
+		return enrollment, utils.NewDatabaseError("Failed to fetch program enrollment", err)
+	}
+	return enrollment, nil
+}
+
+// toEnrollmentResponse maps an enrollment loaded with preloadEnrollment to its
+// DTO, with training maxes in unit
+func toEnrollmentResponse(enrollment models.ProgramEnrollment, unit string) ProgramEnrollmentResponse {
+	response := ProgramEnrollmentResponse{
+		ID:            enrollment.ID,
+		ProgramID:     enrollment.ProgramID,
+		ProgramName:   enrollment.Program.Name,
+		CurrentWeek:   enrollment.CurrentWeek,
+		CurrentDay:    enrollment.CurrentDay,
+		StartedAt:     enrollment.StartedAt,
+		CompletedAt:   enrollment.CompletedAt,
+		WeightUnit:    unit,
+		TrainingMaxes: make([]TrainingMaxResponse, len(enrollment.TrainingMaxes)),
+	}
+	for i, trainingMax := range enrollment.TrainingMaxes {
+		response.TrainingMaxes[i] = TrainingMaxResponse{
+			ExerciseID:   trainingMax.ExerciseID,
+			ExerciseName: trainingMax.Exercise.Name,
+			Weight:       utils.ConvertWeight(trainingMax.Weight, unit),
+		}
+	}
+	return response
+}
+
+// toProgramResponse maps a program loaded by loadUserProgram to its DTO
+func toProgramResponse(program models.Program) ProgramResponse {
+	response := ProgramResponse{
+		ID:          strconv.Itoa(int(program.ID)),
+		CreatedAt:   program.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
+		UpdatedAt:   program.UpdatedAt.Format("2006-01-02T15:04:05Z07:00"),
+		Name:        program.Name,
+		Description: program.Description,
+		UserID:      program.UserID,
+		Weeks:       make([]ProgramWeekResponse, len(program.Weeks)),
+	}
+
+	for i, week := range program.Weeks {
+		days := make([]ProgramDayResponse, len(week.Days))
+		for j, day := range week.Days {
+			sets := make([]ProgramSetResponse, len(day.Sets))
+			for k, set := range day.Sets {
+				sets[k] = ProgramSetResponse{
+					ExerciseID:   set.ExerciseID,
+					ExerciseName: set.Exercise.Name,
+					SetNumber:    set.SetNumber,
+					Percent:      set.Percent,
+					Reps:         set.Reps,
+					AMRAP:        set.AMRAP,
+				}
+			}
+
+			days[j] = ProgramDayResponse{
+				DayNumber:  day.DayNumber,
+				Name:       day.Name,
+				RestDay:    day.TemplateID == nil,
+				TemplateID: day.TemplateID,
+				Sets:       sets,
+			}
+			if day.Template != nil {
+				days[j].TemplateName = day.Template.Name
+			}
+		}
+
+		response.Weeks[i] = ProgramWeekResponse{
+			WeekNumber: week.WeekNumber,
+			Name:       week.Name,
+			Days:       days,
+		}
+	}
+
+	return response
+}
@@ -0,0 +1,296 @@
+package controllers
+
+import (
+	"math"
+
+	"github.com/rachitnimje/trackle-web/models"
+	"github.com/rachitnimje/trackle-web/utils"
+)
+
+// Defaults of a progression rule that leaves them out
+const (
+	defaultProgressionIncrementKg = 2.5
+	defaultDeloadPercent          = 10
+)
+
+// Outcomes of evaluating a progression rule
+const (
+	progressionStart    = "start"
+	progressionIncrease = "increase"
+	progressionRepeat   = "repeat"
+	progressionDeload   = "deload"
+)
+
+// ProgressionRequest configures how the weight of a template exercise
+// progresses. Increment is in the request's unit and defaults to 2.5 kg; the
+// deload percentage defaults to 10 and only applies when DeloadAfter is set.
+type ProgressionRequest struct {
+	Type          string   `json:"type" binding:"required,oneof=none linear double"`
+	Increment     *float64 `json:"increment" binding:"omitempty,gt=0"`
+	RepRangeMin   *int     `json:"rep_range_min" binding:"omitempty,min=1"`
+	RepRangeMax   *int     `json:"rep_range_max" binding:"omitempty,min=1"`
+	DeloadAfter   *int     `json:"deload_after" binding:"omitempty,min=1"`
+	DeloadPercent *float64 `json:"deload_percent" binding:"omitempty,gt=0,lt=100"`
+}
+
+// ProgressionResponse is a template exercise's progression rule with its
+// defaults filled in. Increment is in the response's weight unit.
+type ProgressionResponse struct {
+	Type          string   `json:"type"`
+	Increment     float64  `json:"increment"`
+	RepRangeMin   *int     `json:"rep_range_min"`
+	RepRangeMax   *int     `json:"rep_range_max"`
+	DeloadAfter   *int     `json:"deload_after"`
+	DeloadPercent *float64 `json:"deload_percent"`
+}
+
+// ProgressionPrescription is what a progression rule prescribes for the next
+// workout of an exercise. Weight is in the response's weight unit and Reps
+// holds the target reps of each set.
+type ProgressionPrescription struct {
+	Type           string  `json:"type"`
+	Outcome        string  `json:"outcome"`
+	Weight         float64 `json:"weight"`
+	Reps           []int   `json:"reps"`
+	FailedSessions int     `json:"failed_sessions"`
+}
+
+// validateProgression checks that a progression rule has what its type needs
+func validateProgression(exercise models.Exercise, e CreateTemplateExerciseRequest) *utils.AppError {
+	rule := e.Progression
+	if rule == nil || rule.Type == models.ProgressionNone {
+		return nil
+	}
+
+	if exercise.TrackingType != models.TrackingWeightReps {
+		return utils.NewInvalidInputError(exercise.Name+" is not tracked by weight and reps, so it cannot progress", nil)
+	}
+	if rule.RepRangeMin != nil && rule.RepRangeMax != nil && *rule.RepRangeMax < *rule.RepRangeMin {
+		return utils.NewInvalidInputError("rep_range_max must not be below rep_range_min", nil)
+	}
+
+	switch rule.Type {
+	case models.ProgressionLinear:
+		// every set needs target reps, from its set target or the rep range
+		if rule.RepRangeMin != nil {
+			return nil
+		}
+		if len(e.SetTargets) == 0 {
+			return utils.NewInvalidInputError("Linear progression of "+exercise.Name+" needs target reps or rep_range_min", nil)
+		}
+		for _, target := range e.SetTargets {
+			if target.TargetReps == nil {
+				return utils.NewInvalidInputError("Linear progression of "+exercise.Name+" needs target reps on every set", nil)
+			}
+		}
+	case models.ProgressionDouble:
+		if rule.RepRangeMin == nil || rule.RepRangeMax == nil {
+			return utils.NewInvalidInputError("Double progression of "+exercise.Name+" needs rep_range_min and rep_range_max", nil)
+		}
+	}
+	return nil
+}
+
+// applyProgression copies a progression rule onto a template exercise, with
+// the increment converted from unit to kg
+func applyProgression(templateExercise *models.TemplateExercise, rule *ProgressionRequest, unit string) {
+	templateExercise.ProgressionType = models.ProgressionNone
+	templateExercise.ProgressionIncrement = nil
+	templateExercise.RepRangeMin = nil
+	templateExercise.RepRangeMax = nil
+	templateExercise.DeloadAfter = nil
+	templateExercise.DeloadPercent = nil
+	if rule == nil || rule.Type == models.ProgressionNone {
+		return
+	}
+
+	templateExercise.ProgressionType = rule.Type
+	if rule.Increment != nil {
+		increment := utils.ToKg(*rule.Increment, unit)
+		templateExercise.ProgressionIncrement = &increment
+	}
+	templateExercise.RepRangeMin = rule.RepRangeMin
+	templateExercise.RepRangeMax = rule.RepRangeMax
+	templateExercise.DeloadAfter = rule.DeloadAfter
+	templateExercise.DeloadPercent = rule.DeloadPercent
+}
+
+// toProgressionResponse describes a template exercise's progression rule, or
+// returns nil when it has none
+func toProgressionResponse(templateExercise models.TemplateExercise, unit string) *ProgressionResponse {
+	if templateExercise.ProgressionType == "" || templateExercise.ProgressionType == models.ProgressionNone {
+		return nil
+	}
+
+	response := &ProgressionResponse{
+		Type:        templateExercise.ProgressionType,
+		Increment:   utils.ConvertWeight(progressionIncrement(templateExercise), unit),
+		RepRangeMin: templateExercise.RepRangeMin,
+		RepRangeMax: templateExercise.RepRangeMax,
+		DeloadAfter: templateExercise.DeloadAfter,
+	}
+	if templateExercise.DeloadAfter != nil {
+		deloadPercent := deloadPercent(templateExercise)
+		response.DeloadPercent = &deloadPercent
+	}
+	return response
+}
+
+func progressionIncrement(templateExercise models.TemplateExercise) float64 {
+	if templateExercise.ProgressionIncrement != nil {
+		return *templateExercise.ProgressionIncrement
+	}
+	return defaultProgressionIncrementKg
+}
+
+func deloadPercent(templateExercise models.TemplateExercise) float64 {
+	if templateExercise.DeloadPercent != nil {
+		return *templateExercise.DeloadPercent
+	}
+	return defaultDeloadPercent
+}
+
+// progressionTargetReps returns the target reps of each set of a template
+// exercise: the set's target, else the bottom of the rep range
+func progressionTargetReps(templateExercise models.TemplateExercise) []int {
+	targets := make([]int, templateExercise.Sets)
+	for i := range targets {
+		if templateExercise.RepRangeMin != nil {
+			targets[i] = *templateExercise.RepRangeMin
+		}
+	}
+	if templateExercise.ProgressionType == models.ProgressionDouble {
+		return targets
+	}
+	for _, target := range templateExercise.SetTargets {
+		if target.TargetReps != nil && target.SetNumber >= 1 && target.SetNumber <= len(targets) {
+			targets[target.SetNumber-1] = *target.TargetReps
+		}
+	}
+	return targets
+}
+
+// prescribeProgression evaluates a template exercise's progression rule
+// against its recent workouts, most recent first. Only sets at a workout's top
+// weight count towards the rule. It returns nil when the exercise has no rule,
+// and a start prescription when it has no history yet.
+func prescribeProgression(templateExercise models.TemplateExercise, sessions []exerciseHistory) *ProgressionPrescription {
+	if templateExercise.ProgressionType == "" || templateExercise.ProgressionType == models.ProgressionNone {
+		return nil
+	}
+	if templateExercise.ProgressionType == models.ProgressionDouble &&
+		(templateExercise.RepRangeMin == nil || templateExercise.RepRangeMax == nil) {
+		return nil
+	}
+
+	targets := progressionTargetReps(templateExercise)
+	prescription := &ProgressionPrescription{
+		Type:    templateExercise.ProgressionType,
+		Outcome: progressionStart,
+		Reps:    targets,
+	}
+
+	var evaluated []progressionResult
+	for _, session := range sessions {
+		if result, ok := evaluateProgressionSession(templateExercise, targets, session); ok {
+			evaluated = append(evaluated, result)
+		}
+	}
+	if len(evaluated) == 0 {
+		return prescription
+	}
+
+	last := evaluated[0]
+	prescription.Weight = last.weight
+
+	// failed workouts in a row at the current weight
+	for _, result := range evaluated {
+		if !result.failed || math.Abs(result.weight-last.weight) > weightTolerance {
+			break
+		}
+		prescription.FailedSessions++
+	}
+
+	switch {
+	case templateExercise.DeloadAfter != nil && prescription.FailedSessions >= *templateExercise.DeloadAfter:
+		prescription.Outcome = progressionDeload
+		prescription.Weight = last.weight * (1 - deloadPercent(templateExercise)/100)
+	case last.succeeded:
+		prescription.Outcome = progressionIncrease
+		prescription.Weight = last.weight + progressionIncrement(templateExercise)
+	default:
+		prescription.Outcome = progressionRepeat
+		if templateExercise.ProgressionType == models.ProgressionDouble && !last.failed {
+			// work up the rep range one rep at a time
+			reps := min(last.lowestReps+1, *templateExercise.RepRangeMax)
+			for i := range prescription.Reps {
+				prescription.Reps[i] = reps
+			}
+		}
+	}
+	return prescription
+}
+
+// weightTolerance absorbs rounding when comparing weights converted between units
+const weightTolerance = 0.01
+
+// progressionResult is how a past workout measured up to a progression rule
+type progressionResult struct {
+	weight     float64
+	lowestReps int
+	succeeded  bool
+	failed     bool
+}
+
+// evaluateProgressionSession reads a past workout of an exercise. It reports
+// false when the workout has no weighted sets to judge.
+func evaluateProgressionSession(templateExercise models.TemplateExercise, targets []int, session exerciseHistory) (progressionResult, bool) {
+	var result progressionResult
+	for _, set := range session.Sets {
+		if set.Reps > 0 {
+			result.weight = math.Max(result.weight, set.Weight)
+		}
+	}
+	if result.weight == 0 {
+		return result, false
+	}
+
+	var reps []int
+	for _, set := range session.Sets {
+		if set.Reps > 0 && math.Abs(set.Weight-result.weight) <= weightTolerance {
+			reps = append(reps, set.Reps)
+		}
+	}
+	result.lowestReps = reps[0]
+	for _, r := range reps {
+		result.lowestReps = min(result.lowestReps, r)
+	}
+
+	// fewer sets at the top weight than prescribed is never a success
+	if len(reps) < len(targets) {
+		result.failed = true
+		return result, true
+	}
+
+	switch templateExercise.ProgressionType {
+	case models.ProgressionDouble:
+		result.succeeded = true
+		for _, r := range reps[:len(targets)] {
+			if r < *templateExercise.RepRangeMax {
+				result.succeeded = false
+			}
+			if r < *templateExercise.RepRangeMin {
+				result.failed = true
+			}
+		}
+	default:
+		result.succeeded = true
+		for i, r := range reps[:len(targets)] {
+			if r < targets[i] {
+				result.succeeded = false
+				result.failed = true
+			}
+		}
+	}
+	return result, true
+}
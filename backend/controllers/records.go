@@ -0,0 +1,319 @@
+package controllers
+
+import (
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/rachitnimje/trackle-web/models"
+	"github.com/rachitnimje/trackle-web/utils"
+)
+
+type PersonalRecordResponse struct {
+	ID           uint      `json:"id"`
+	ExerciseID   uint      `json:"exercise_id"`
+	ExerciseName string    `json:"exercise_name"`
+	WorkoutID    uint      `json:"workout_id"`
+	Type         string    `json:"type"`
+	Value        float64   `json:"value"`
+	Weight       float64   `json:"weight"`
+	Reps         int       `json:"reps"`
+	AchievedAt   time.Time `json:"achieved_at"`
+	IsCurrent    bool      `json:"is_current"`
+}
+
+// WorkoutSaveResponse is returned when a workout is created or updated, with
+// the personal records it set so the client can celebrate them. Weights are in
+// WeightUnit.
+type WorkoutSaveResponse struct {
+	models.Workout
+	WeightUnit string                   `json:"weight_unit"`
+	NewRecords []PersonalRecordResponse `json:"new_records"`
+}
+
+// recordEntry is a workout entry joined with its workout date, in the order
+// records are detected
+type recordEntry struct {
+	EntryID    uint
+	WorkoutID  uint
+	ExerciseID uint
+	Reps       int
+	Weight     float64
+	AchievedAt time.Time
+}
+
+// recomputePersonalRecords rebuilds the user's record history for the given
+// exercises by replaying their finished workouts in order. Replaying (instead
+// of comparing against stored bests) keeps records correct when an older
+// workout is edited or deleted.
+func recomputePersonalRecords(tx *gorm.DB, userID uint, exerciseIDs []uint) error {
+	if len(exerciseIDs) == 0 {
+		return nil
+	}
+
+	var entries []recordEntry
+	if err := tx.Table("workout_entries").
+		Select("workout_entries.id AS entry_id, workout_entries.workout_id, workout_entries.exercise_id, "+
+			"workout_entries.reps, workout_entries.weight, workouts.performed_at AS achieved_at").
+		Joins("JOIN workouts ON workouts.id = workout_entries.workout_id AND workouts.deleted_at IS NULL").
+		Where("workout_entries.deleted_at IS NULL AND workouts.user_id = ? AND workout_entries.exercise_id IN ?", userID, exerciseIDs).
+		// live sessions only count once they are finished
+		Where("workouts.started_at IS NULL OR workouts.finished_at IS NOT NULL").
+		// warm-ups never count as records
+		Where("workout_entries.set_type <> ?", models.SetTypeWarmup).
+		// timed and cardio sets have no reps to rank
+		Where("workout_entries.reps > 0").
+		Order("workouts.performed_at, workouts.id, workout_entries.set_number, workout_entries.id").
+		Scan(&entries).Error; err != nil {
+		return utils.NewDatabaseError("Failed to load workout history", err)
+	}
+
+	var existing []models.PersonalRecord
+	if err := tx.Where("user_id = ? AND exercise_id IN ?", userID, exerciseIDs).
+		Find(&existing).Error; err != nil {
+		return utils.NewDatabaseError("Failed to load personal records", err)
+	}
+
+	byExercise := make(map[uint][]recordEntry)
+	for _, entry := range entries {
+		byExercise[entry.ExerciseID] = append(byExercise[entry.ExerciseID], entry)
+	}
+
+	var records []models.PersonalRecord
+	for _, exerciseEntries := range byExercise {
+		records = append(records, detectPersonalRecords(userID, exerciseEntries)...)
+	}
+
+	// Keep the stored records that were detected again, so their IDs stay
+	// stable across saves, and only replace the ones that changed
+	stored := make(map[recordKey][]models.PersonalRecord)
+	for _, record := range existing {
+		key := keyOf(record)
+		stored[key] = append(stored[key], record)
+	}
+
+	var created []models.PersonalRecord
+	for _, record := range records {
+		key := keyOf(record)
+		matches := stored[key]
+		if len(matches) == 0 {
+			created = append(created, record)
+			continue
+		}
+		match := matches[0]
+		stored[key] = matches[1:]
+
+		if match.IsCurrent != record.IsCurrent {
+			if err := tx.Model(&match).Update("is_current", record.IsCurrent).Error; err != nil {
+				return utils.NewDatabaseError("Failed to update personal records", err)
+			}
+		}
+	}
+
+	var staleIDs []uint
+	for _, matches := range stored {
+		for _, record := range matches {
+			staleIDs = append(staleIDs, record.ID)
+		}
+	}
+	if len(staleIDs) > 0 {
+		if err := tx.Unscoped().Delete(&models.PersonalRecord{}, staleIDs).Error; err != nil {
+			return utils.NewDatabaseError("Failed to clear personal records", err)
+		}
+	}
+
+	if len(created) == 0 {
+		return nil
+	}
+	if err := tx.Create(&created).Error; err != nil {
+		return utils.NewDatabaseError("Failed to save personal records", err)
+	}
+	return nil
+}
+
+// recordKey identifies a record by what it records, so a recomputed record
+// can be matched to the stored one it replaces
+type recordKey struct {
+	ExerciseID     uint
+	WorkoutID      uint
+	WorkoutEntryID uint
+	Type           string
+	Value          float64
+	Weight         float64
+	Reps           int
+	AchievedAt     int64
+}
+
+func keyOf(record models.PersonalRecord) recordKey {
+	key := recordKey{
+		ExerciseID: record.ExerciseID,
+		WorkoutID:  record.WorkoutID,
+		Type:       record.Type,
+		Value:      record.Value,
+		Weight:     record.Weight,
+		Reps:       record.Reps,
+		AchievedAt: record.AchievedAt.UnixNano(),
+	}
+	if record.WorkoutEntryID != nil {
+		key.WorkoutEntryID = *record.WorkoutEntryID
+	}
+	return key
+}
+
+// detectPersonalRecords walks one exercise's entries in workout order and
+// returns every record set along the way
+func detectPersonalRecords(userID uint, entries []recordEntry) []models.PersonalRecord {
+	var records []models.PersonalRecord
+	var bestWeight, best1RM, bestVolume float64
+
+	// add appends a record and retires the records of the same type it beats
+	add := func(recordType string, value float64, entry recordEntry, entryID *uint) {
+		for i := range records {
+			if records[i].Type != recordType || !records[i].IsCurrent {
+				continue
+			}
+			// a reps record only beats lighter-or-equal sets with fewer-or-equal reps
+			if recordType == models.RecordRepsAtWeight &&
+				(records[i].Weight > entry.Weight || records[i].Reps > entry.Reps) {
+				continue
+			}
+			records[i].IsCurrent = false
+		}
+
+		records = append(records, models.PersonalRecord{
+			UserID:         userID,
+			ExerciseID:     entry.ExerciseID,
+			WorkoutID:      entry.WorkoutID,
+			WorkoutEntryID: entryID,
+			Type:           recordType,
+			Value:          value,
+			Weight:         entry.Weight,
+			Reps:           entry.Reps,
+			AchievedAt:     entry.AchievedAt,
+			IsCurrent:      true,
+		})
+	}
+
+	for start := 0; start < len(entries); {
+		// collect the sets of one workout
+		end := start
+		for end < len(entries) && entries[end].WorkoutID == entries[start].WorkoutID {
+			end++
+		}
+		sets := entries[start:end]
+		start = end
+
+		var heaviest, strongest recordEntry
+		var heaviest1RM, volume float64
+		bestRepsByWeight := make(map[float64]recordEntry)
+		for _, set := range sets {
+			if set.Weight > heaviest.Weight || (set.Weight == heaviest.Weight && set.Reps > heaviest.Reps) {
+				heaviest = set
+			}
+			if e1RM := utils.EstimateOneRepMax(set.Weight, set.Reps); e1RM > heaviest1RM {
+				heaviest1RM = e1RM
+				strongest = set
+			}
+			volume += set.Weight * float64(set.Reps)
+			if best, ok := bestRepsByWeight[set.Weight]; !ok || set.Reps > best.Reps {
+				bestRepsByWeight[set.Weight] = set
+			}
+		}
+
+		if heaviest.Weight > bestWeight {
+			bestWeight = heaviest.Weight
+			add(models.RecordMaxWeight, heaviest.Weight, heaviest, &heaviest.EntryID)
+		}
+
+		if heaviest1RM > best1RM {
+			best1RM = heaviest1RM
+			add(models.RecordEstimated1RM, heaviest1RM, strongest, &strongest.EntryID)
+		}
+
+		if volume > bestVolume {
+			bestVolume = volume
+			// a session volume record belongs to the workout, not to a single set
+			session := sets[0]
+			session.Weight, session.Reps = 0, 0
+			add(models.RecordSessionVolume, volume, session, nil)
+		}
+
+		// heavier sets first, so a lighter set with the same reps is not a record
+		weights := make([]float64, 0, len(bestRepsByWeight))
+		for weight := range bestRepsByWeight {
+			weights = append(weights, weight)
+		}
+		sort.Sort(sort.Reverse(sort.Float64Slice(weights)))
+
+		for _, weight := range weights {
+			set := bestRepsByWeight[weight]
+			previousBest := 0
+			for _, record := range records {
+				if record.Type == models.RecordRepsAtWeight && record.Weight >= weight && record.Reps > previousBest {
+					previousBest = record.Reps
+				}
+			}
+			if set.Reps > previousBest {
+				add(models.RecordRepsAtWeight, float64(set.Reps), set, &set.EntryID)
+			}
+		}
+	}
+
+	return records
+}
+
+// workoutRecords loads the personal records set in a workout that still stand,
+// with weights in unit
+func workoutRecords(db *gorm.DB, workoutID uint, unit string) ([]PersonalRecordResponse, error) {
+	var records []models.PersonalRecord
+	if err := db.Where("workout_id = ? AND is_current = ?", workoutID, true).
+		Preload("Exercise").
+		Order("exercise_id, type").
+		Find(&records).Error; err != nil {
+		return nil, err
+	}
+	return toPersonalRecordResponses(records, unit), nil
+}
+
+// workoutExerciseIDs returns the distinct exercises logged in a workout
+func workoutExerciseIDs(db *gorm.DB, workoutID uint) ([]uint, error) {
+	var exerciseIDs []uint
+	err := db.Model(&models.WorkoutEntry{}).
+		Where("workout_id = ?", workoutID).
+		Distinct().
+		Pluck("exercise_id", &exerciseIDs).Error
+	return exerciseIDs, err
+}
+
+// toPersonalRecordResponses maps records to their DTOs with weights converted to unit
+func toPersonalRecordResponses(records []models.PersonalRecord, unit string) []PersonalRecordResponse {
+	response := make([]PersonalRecordResponse, 0, len(records))
+	for _, record := range records {
+		response = append(response, PersonalRecordResponse{
+			ID:           record.ID,
+			ExerciseID:   record.ExerciseID,
+			ExerciseName: record.Exercise.Name,
+			WorkoutID:    record.WorkoutID,
+			Type:         record.Type,
+			Value:        recordValue(record.Type, record.Value, unit),
+			Weight:       utils.DisplayWeight(record.Weight, unit),
+			Reps:         record.Reps,
+			AchievedAt:   record.AchievedAt,
+			IsCurrent:    record.IsCurrent,
+		})
+	}
+	return response
+}
+
+// recordValue converts a record's value to unit. Reps records are unitless.
+func recordValue(recordType string, value float64, unit string) float64 {
+	switch recordType {
+	case models.RecordMaxWeight:
+		return utils.DisplayWeight(value, unit)
+	case models.RecordEstimated1RM, models.RecordSessionVolume:
+		return utils.ConvertWeight(value, unit)
+	default:
+		return value
+	}
+}
@@ -0,0 +1,530 @@
+package controllers
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/rachitnimje/trackle-web/metrics"
+	"github.com/rachitnimje/trackle-web/models"
+	"github.com/rachitnimje/trackle-web/utils"
+)
+
+type StartWorkoutRequest struct {
+	TemplateID uint   `json:"template_id" binding:"required"`
+	Name       string `json:"name"`
+}
+
+// SessionEntryRequest logs or edits a single set of a live workout. When the
+// set number is omitted the set is appended after the exercise's last set. The
+// weight is in Unit, or in the user's preferred unit when it is omitted. Set
+// type and effort follow the rules of WorkoutEntryRequest. A set logged without
+// a group takes its exercise's group in the workout's template.
+type SessionEntryRequest struct {
+	ExerciseID      uint     `json:"exercise_id" binding:"required"`
+	SetNumber       int      `json:"set_number" binding:"omitempty,min=1"`
+	SetType         string   `json:"set_type" binding:"omitempty,oneof=warmup working drop failure amrap"`
+	Reps            int      `json:"reps" binding:"min=0"`
+	Weight          float64  `json:"weight" binding:"min=0"`
+	Unit            string   `json:"unit" binding:"omitempty,oneof=kg lb"`
+	DurationSeconds *int     `json:"duration_seconds" binding:"omitempty,min=1"`
+	DistanceMeters  *float64 `json:"distance_meters" binding:"omitempty,gt=0"`
+	RPE             *float64 `json:"rpe" binding:"omitempty,min=1,max=10,excluded_with=RIR"`
+	RIR             *int     `json:"rir" binding:"omitempty,min=0,max=10"`
+	Note            string   `json:"note" binding:"max=500"`
+	GroupID         *int     `json:"group_id" binding:"required_with=GroupType,omitempty,min=1"`
+	GroupType       string   `json:"group_type" binding:"required_with=GroupID,omitempty,oneof=superset circuit giant_set"`
+}
+
+type FinishWorkoutRequest struct {
+	Notes *string `json:"notes"`
+}
+
+// StartUserWorkout starts a live workout session from one of the user's templates.
+// A user can only have one active session at a time.
+func StartUserWorkout(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
+		// extract user_id from context
+		userID, exists := c.Get("user_id")
+		if !exists {
+			appErr := utils.NewAuthenticationError("User not authenticated", nil)
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
+		// bind and validate request
+		var req StartWorkoutRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			appErr := utils.NewValidationError("Invalid request data", err)
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
+		// check if the template belongs to the user
+		var template models.Template
+		if err := db.Where("id = ? and user_id = ?", req.TemplateID, userID).
+			First(&template).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				appErr := utils.NewNotFoundError("Template not found or access denied", nil)
+				utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			} else {
+				appErr := utils.NewDatabaseError("Failed to verify template", err)
+				utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			}
+			return
+		}
+
+		name := req.Name
+		if name == "" {
+			name = template.Name
+		}
+
+		var startedWorkout models.Workout
+		var appErr *utils.AppError
+		err := utils.TransactionManager(db, c, func(tx *gorm.DB) error {
+			// only one active session per user
+			var activeCount int64
+			if err := activeSessions(tx, userID).Count(&activeCount).Error; err != nil {
+				appErr = utils.NewDatabaseError("Failed to check for an active workout", err)
+				return appErr
+			}
+			if activeCount > 0 {
+				appErr = utils.NewDuplicateEntryError("A workout is already in progress", nil)
+				return appErr
+			}
+
+			now := time.Now()
+			workout := models.Workout{
+				Name:        name,
+				UserID:      userID.(uint),
+				TemplateID:  template.ID,
+				PerformedAt: now,
+				StartedAt:   &now,
+			}
+
+			// the active session index catches a concurrent start
+			if err := tx.Create(&workout).Error; err != nil {
+				if utils.IsUniqueViolation(err) {
+					appErr = utils.NewDuplicateEntryError("A workout is already in progress", nil)
+				} else {
+					appErr = utils.NewDatabaseError("Failed to start workout", err)
+				}
+				return appErr
+			}
+
+			startedWorkout = workout
+			startedWorkout.Template = template
+			return nil
+		})
+
+		if appErr != nil {
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+		if err != nil {
+			// the failed commit has been written
+			return
+		}
+
+		preferredUnit, appErr := preferredWeightUnit(db, userID)
+		if appErr != nil {
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
+		utils.CreatedResponse(c, "Workout started successfully", toUserWorkoutResponse(startedWorkout, nil, preferredUnit))
+	}
+}
+
+// GetActiveUserWorkout returns the user's workout session in progress, if any
+func GetActiveUserWorkout(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
+		// extract user_id from context
+		userID, exists := c.Get("user_id")
+		if !exists {
+			appErr := utils.NewAuthenticationError("User not authenticated", nil)
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
+		var workout models.Workout
+		if err := activeSessions(db, userID).
+			Preload("Template").
+			Order("started_at DESC").
+			First(&workout).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				appErr := utils.NewNotFoundError("No workout in progress", nil)
+				utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			} else {
+				appErr := utils.NewDatabaseError("Failed to retrieve active workout", err)
+				utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			}
+			return
+		}
+
+		respondWithSession(db, c, workout, "Active workout retrieved successfully")
+	}
+}
+
+// AddSessionEntry appends a set to a workout in progress
+func AddSessionEntry(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
+		workout, appErr := findActiveSession(db, c)
+		if appErr != nil {
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
+		// bind and validate request
+		var req SessionEntryRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			appErr := utils.NewValidationError("Invalid request data", err)
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
+		if appErr := verifySessionEntry(db, workout.UserID, req); appErr != nil {
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
+		preferredUnit, appErr := preferredWeightUnit(db, workout.UserID)
+		if appErr != nil {
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
+		setNumber := req.SetNumber
+		if setNumber == 0 {
+			var lastSet int
+			if err := db.Model(&models.WorkoutEntry{}).
+				Select("COALESCE(MAX(set_number), 0)").
+				Where("workout_id = ? AND exercise_id = ?", workout.ID, req.ExerciseID).
+				Scan(&lastSet).Error; err != nil {
+				appErr := utils.NewDatabaseError("Failed to determine set number", err)
+				utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+				return
+			}
+			setNumber = lastSet + 1
+		}
+
+		groupID, groupType := req.GroupID, entryGroupType(req.GroupID, req.GroupType)
+		if groupID == nil {
+			var err error
+			groupID, groupType, err = templateExerciseGroup(db, workout.TemplateID, req.ExerciseID)
+			if err != nil {
+				appErr := utils.NewDatabaseError("Failed to determine exercise group", err)
+				utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+				return
+			}
+		}
+
+		entry := models.WorkoutEntry{
+			WorkoutID:       workout.ID,
+			ExerciseID:      req.ExerciseID,
+			SetNumber:       setNumber,
+			SetType:         entrySetType(req.SetType),
+			Reps:            req.Reps,
+			Weight:          utils.ToKg(req.Weight, requestWeightUnit(req.Unit, preferredUnit)),
+			DurationSeconds: req.DurationSeconds,
+			DistanceMeters:  req.DistanceMeters,
+			RPE:             req.RPE,
+			RIR:             req.RIR,
+			Note:            req.Note,
+			GroupID:         groupID,
+			GroupType:       groupType,
+		}
+
+		if err := db.Create(&entry).Error; err != nil {
+			appErr := utils.NewDatabaseError("Failed to log set", err)
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
+		respondWithSession(db, c, workout, "Set logged successfully")
+	}
+}
+
+// UpdateSessionEntry edits a set of a workout in progress
+func UpdateSessionEntry(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
+		workout, appErr := findActiveSession(db, c)
+		if appErr != nil {
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
+		entry, appErr := findSessionEntry(db, c, workout.ID)
+		if appErr != nil {
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
+		// bind and validate request
+		var req SessionEntryRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			appErr := utils.NewValidationError("Invalid request data", err)
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
+		if appErr := verifySessionEntry(db, workout.UserID, req); appErr != nil {
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
+		preferredUnit, appErr := preferredWeightUnit(db, workout.UserID)
+		if appErr != nil {
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
+		entry.ExerciseID = req.ExerciseID
+		entry.Reps = req.Reps
+		entry.Weight = utils.ToKg(req.Weight, requestWeightUnit(req.Unit, preferredUnit))
+		entry.SetType = entrySetType(req.SetType)
+		entry.DurationSeconds = req.DurationSeconds
+		entry.DistanceMeters = req.DistanceMeters
+		entry.RPE = req.RPE
+		entry.RIR = req.RIR
+		entry.Note = req.Note
+		if req.GroupID != nil {
+			entry.GroupID = req.GroupID
+			entry.GroupType = entryGroupType(req.GroupID, req.GroupType)
+		}
+		if req.SetNumber != 0 {
+			entry.SetNumber = req.SetNumber
+		}
+
+		if err := db.Omit("Workout", "Exercise").Save(&entry).Error; err != nil {
+			appErr := utils.NewDatabaseError("Failed to update set", err)
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
+		respondWithSession(db, c, workout, "Set updated successfully")
+	}
+}
+
+// DeleteSessionEntry removes a set from a workout in progress
+func DeleteSessionEntry(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
+		workout, appErr := findActiveSession(db, c)
+		if appErr != nil {
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
+		entry, appErr := findSessionEntry(db, c, workout.ID)
+		if appErr != nil {
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
+		if err := db.Delete(&entry).Error; err != nil {
+			appErr := utils.NewDatabaseError("Failed to delete set", err)
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
+		respondWithSession(db, c, workout, "Set deleted successfully")
+	}
+}
+
+// FinishUserWorkout ends a workout in progress and records its duration
+func FinishUserWorkout(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
+		workout, appErr := findActiveSession(db, c)
+		if appErr != nil {
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
+		var req FinishWorkoutRequest
+		if c.Request.ContentLength > 0 {
+			if err := c.ShouldBindJSON(&req); err != nil {
+				appErr := utils.NewValidationError("Invalid request data", err)
+				utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+				return
+			}
+		}
+
+		// a finished workout needs at least one set; empty sessions should be deleted instead
+		var entryCount int64
+		if err := db.Model(&models.WorkoutEntry{}).Where("workout_id = ?", workout.ID).Count(&entryCount).Error; err != nil {
+			appErr := utils.NewDatabaseError("Failed to count logged sets", err)
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+		if entryCount == 0 {
+			appErr := utils.NewInvalidInputError("Log at least one set before finishing the workout", nil)
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
+		now := time.Now()
+		workout.FinishedAt = &now
+		workout.DurationMinutes = workoutDuration(workout.StartedAt, workout.FinishedAt)
+		if req.Notes != nil {
+			workout.Notes = *req.Notes
+		}
+
+		err := utils.TransactionManager(db, c, func(tx *gorm.DB) error {
+			if err := tx.Omit("User", "Template", "Entries").Save(&workout).Error; err != nil {
+				appErr = utils.NewDatabaseError("Failed to finish workout", err)
+				return appErr
+			}
+
+			// The session's sets only count towards personal records once it is finished
+			exerciseIDs, err := workoutExerciseIDs(tx, workout.ID)
+			if err != nil {
+				appErr = utils.NewDatabaseError("Failed to load workout entries", err)
+				return appErr
+			}
+			if err := recomputePersonalRecords(tx, workout.UserID, exerciseIDs); err != nil {
+				if !errors.As(err, &appErr) {
+					appErr = utils.NewDatabaseError("Failed to update personal records", err)
+				}
+				return appErr
+			}
+
+			return nil
+		})
+
+		if appErr != nil {
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+		if err != nil {
+			// the failed commit has been written
+			return
+		}
+
+		metrics.WorkoutsLogged.WithLabelValues(metrics.WorkoutSourceSession).Inc()
+		respondWithSession(db, c, workout, "Workout finished successfully")
+	}
+}
+
+// activeSessions scopes a workout query to the user's unfinished live sessions
+func activeSessions(db *gorm.DB, userID interface{}) *gorm.DB {
+	return db.Model(&models.Workout{}).
+		Where("user_id = ? AND started_at IS NOT NULL AND finished_at IS NULL", userID)
+}
+
+// findActiveSession loads the user's workout identified by the :id param and
+// checks it is still in progress
+func findActiveSession(db *gorm.DB, c *gin.Context) (models.Workout, *utils.AppError) {
+	var workout models.Workout
+
+	// extract user_id from context
+	userID, exists := c.Get("user_id")
+	if !exists {
+		return workout, utils.NewAuthenticationError("User not authenticated", nil)
+	}
+
+	// parse the workout ID with validation
+	workoutID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil || workoutID == 0 {
+		return workout, utils.NewInvalidInputError("Invalid workout ID", err)
+	}
+
+	if err := db.Where("id = ? AND user_id = ?", workoutID, userID).
+		Preload("Template").
+		First(&workout).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return workout, utils.NewNotFoundError("Workout not found or access denied", nil)
+		}
+		return workout, utils.NewDatabaseError("Failed to retrieve workout", err)
+	}
+
+	if !workout.IsActive() {
+		return workout, utils.NewInvalidInputError("Workout is not in progress", nil)
+	}
+
+	return workout, nil
+}
+
+// findSessionEntry loads the workout entry identified by the :entryId param
+func findSessionEntry(db *gorm.DB, c *gin.Context, workoutID uint) (models.WorkoutEntry, *utils.AppError) {
+	var entry models.WorkoutEntry
+
+	entryID, err := strconv.ParseUint(c.Param("entryId"), 10, 32)
+	if err != nil || entryID == 0 {
+		return entry, utils.NewInvalidInputError("Invalid entry ID", err)
+	}
+
+	if err := db.Where("id = ? AND workout_id = ?", entryID, workoutID).First(&entry).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return entry, utils.NewNotFoundError("Set not found", nil)
+		}
+		return entry, utils.NewDatabaseError("Failed to retrieve set", err)
+	}
+
+	return entry, nil
+}
+
+// verifySessionEntry checks the set's exercise exists and is visible to the
+// user, and that the set records what the exercise tracks
+func verifySessionEntry(db *gorm.DB, userID uint, req SessionEntryRequest) *utils.AppError {
+	var exercise models.Exercise
+	if err := visibleExercises(db.Model(&models.Exercise{}), userID).
+		Where("id = ?", req.ExerciseID).
+		First(&exercise).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return utils.NewInvalidInputError("Invalid exercise ID", nil)
+		}
+		return utils.NewDatabaseError("Failed to verify exercise", err)
+	}
+
+	return validateEntryMetrics(exercise, req.Reps, req.Weight, req.DurationSeconds, req.DistanceMeters)
+}
+
+// respondWithSession reloads the workout's entries and writes the session
+// response in the user's preferred unit
+func respondWithSession(db *gorm.DB, c *gin.Context, workout models.Workout, message string) {
+	preferredUnit, appErr := preferredWeightUnit(db, workout.UserID)
+	if appErr != nil {
+		utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+		return
+	}
+
+	var entries []models.WorkoutEntry
+	if err := db.Where("workout_id = ?", workout.ID).
+		Preload("Exercise").
+		Order("id").
+		Find(&entries).Error; err != nil {
+		appErr := utils.NewDatabaseError("Failed to retrieve workout entries", err)
+		utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+		return
+	}
+
+	response := toUserWorkoutResponse(workout, entries, preferredUnit)
+
+	// report the records a finished session set
+	if !workout.IsActive() {
+		newRecords, err := workoutRecords(db, workout.ID, preferredUnit)
+		if err != nil {
+			appErr := utils.NewDatabaseError("Failed to load personal records", err)
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+		response.NewRecords = newRecords
+	}
+
+	utils.SuccessResponse(c, message, response)
+}
@@ -1,7 +1,11 @@
 package controllers
 
 import (
+	"errors"
+	"math"
 	"net/http"
+	"slices"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -10,118 +14,154 @@ import (
 	"gorm.io/gorm"
 )
 
-// GetWorkoutStats returns statistics about workout frequency
+// GetWorkoutStats returns statistics about workout frequency, counted by the
+// day (or month) the workouts were performed in the user's timezone
 func GetWorkoutStats(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
 		// Get user ID from token
-		userID, exists := c.Get("userID")
+		userID, exists := c.Get("user_id")
 		if !exists {
 			utils.ErrorResponse(c, http.StatusUnauthorized, "User not authenticated", nil)
 			return
 		}
 
-		// Get time range from query params (default to month)
-		timeRange := c.DefaultQuery("timeRange", "month")
-
-		// Determine the start date based on time range
-		var startDate time.Time
-		now := time.Now()
-		
-		switch timeRange {
-		case "week":
-			startDate = now.AddDate(0, 0, -7)
-		case "year":
-			startDate = now.AddDate(-1, 0, 0)
-		default: // month
-			startDate = now.AddDate(0, -1, 0)
-		}
-
-		// Query to get workout counts by date
-		var results []struct {
-			Date  time.Time
-			Count int
-		}
-
-		// Format date according to time range
-		var dateFormat string
-		var labels []string
-		
-		switch timeRange {
-		case "week":
-			dateFormat = "Mon" // Day of week
-		case "year":
-			dateFormat = "Jan" // Month
-		default: // month
-			dateFormat = "Jan 02" // Month and day
-		}
-
-		// Get workouts grouped by date
-		query := db.Model(&models.Workout{}).
-			Select("DATE(created_at) as date, COUNT(*) as count").
-			Where("user_id = ? AND created_at >= ?", userID, startDate).
-			Group("DATE(created_at)").
-			Order("date")
-		
-		if err := query.Find(&results).Error; err != nil {
-			utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch workout statistics", err)
+		loc, appErr := preferredLocation(db, userID)
+		if appErr != nil {
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
 			return
 		}
 
-		// Prepare data for response
-		var data []int
-		dateMap := make(map[string]int)
-
-		// Process results
-		for _, r := range results {
-			formattedDate := r.Date.Format(dateFormat)
-			dateMap[formattedDate] = r.Count
-		}
+		// Get time range from query params (default to month)
+		timeRange := c.DefaultQuery("timeRange", "month")
 
-		// Generate complete date range based on time range
+		// Generate the complete date range and its label format, in the user's timezone
+		now := time.Now().In(loc)
+		today := utils.BucketStart(now, utils.BucketDay)
+		var dateFormat string
 		var allDates []time.Time
-		
+
 		switch timeRange {
 		case "week":
 			// Last 7 days
+			dateFormat = "Mon" // Day of week
 			for i := 6; i >= 0; i-- {
-				allDates = append(allDates, now.AddDate(0, 0, -i))
+				allDates = append(allDates, today.AddDate(0, 0, -i))
 			}
 		case "year":
 			// Last 12 months
+			dateFormat = "Jan" // Month
+			thisMonth := utils.BucketStart(now, utils.BucketMonth)
 			for i := 11; i >= 0; i-- {
-				allDates = append(allDates, now.AddDate(0, -i, 0))
+				allDates = append(allDates, thisMonth.AddDate(0, -i, 0))
 			}
 		default: // month
 			// Last 30 days
+			dateFormat = "Jan 02" // Month and day
 			for i := 29; i >= 0; i-- {
-				allDates = append(allDates, now.AddDate(0, 0, -i))
+				allDates = append(allDates, today.AddDate(0, 0, -i))
 			}
 		}
 
+		// Get the workouts performed in the range; they are grouped by date
+		// here, where the user's timezone applies
+		var performedAts []time.Time
+		if err := db.Model(&models.Workout{}).
+			Where("user_id = ? AND performed_at >= ?", userID, allDates[0]).
+			Pluck("performed_at", &performedAts).Error; err != nil {
+			utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch workout statistics", err)
+			return
+		}
+
+		dateMap := make(map[string]int)
+		for _, performedAt := range performedAts {
+			dateMap[performedAt.In(loc).Format(dateFormat)]++
+		}
+
 		// Fill in the labels and data
+		labels := make([]string, 0, len(allDates))
+		data := make([]int, 0, len(allDates))
 		for _, date := range allDates {
 			formattedDate := date.Format(dateFormat)
 			labels = append(labels, formattedDate)
-			count, exists := dateMap[formattedDate]
-			if exists {
-				data = append(data, count)
-			} else {
-				data = append(data, 0)
-			}
+			data = append(data, dateMap[formattedDate])
 		}
 
 		c.JSON(http.StatusOK, gin.H{
-			"labels": labels,
-			"data":   data,
+			"labels":   labels,
+			"data":     data,
+			"timezone": loc.String(),
 		})
 	}
 }
 
-// GetExerciseProgress returns the progress of a specific exercise over time
+// Exercise progress metrics
+const (
+	metricMaxWeight   = "max_weight"
+	metricE1RM        = "e1rm"
+	metricVolume      = "volume"
+	metricReps        = "reps"
+	metricBestSet     = "best_set"
+	metricIntensity   = "intensity"
+	metricMaxReps     = "max_reps"
+	metricDuration    = "duration"
+	metricMaxDuration = "max_duration"
+	metricDistance    = "distance"
+	metricPace        = "pace"
+)
+
+// progressMetrics lists the metrics available for each tracking type; the
+// first one is the default
+var progressMetrics = map[string][]string{
+	models.TrackingWeightReps:       {metricMaxWeight, metricE1RM, metricVolume, metricReps, metricBestSet, metricIntensity},
+	models.TrackingRepsOnly:         {metricReps, metricMaxReps},
+	models.TrackingDuration:         {metricMaxDuration, metricDuration},
+	models.TrackingDistanceDuration: {metricDistance, metricDuration, metricPace},
+}
+
+// progressMetricUnits names the unit of the metrics that are not weights
+var progressMetricUnits = map[string]string{
+	metricReps:        "reps",
+	metricMaxReps:     "reps",
+	metricIntensity:   "%",
+	metricDuration:    "s",
+	metricMaxDuration: "s",
+	metricDistance:    "m",
+	metricPace:        "s/km",
+}
+
+// maxSmoothingWindow caps the moving-average window of exercise progress
+const maxSmoothingWindow = 12
+
+// ProgressPoint is one bucket of an exercise progress series. For best_set the
+// point also carries the set that scored best.
+type ProgressPoint struct {
+	Date   string  `json:"date"`
+	Value  float64 `json:"value"`
+	Sets   int     `json:"sets"`
+	Weight float64 `json:"weight,omitempty"`
+	Reps   int     `json:"reps,omitempty"`
+}
+
+// GetExerciseProgress returns the progress of a specific exercise over time.
+//
+// Query parameters:
+//   - metric: depends on the exercise's tracking type (the first is the default)
+//   - weight_reps: max_weight, e1rm, volume, reps, best_set or intensity
+//   - reps_only: reps or max_reps
+//   - duration: max_duration or duration (total seconds)
+//   - distance_duration: distance (total meters), duration or pace (seconds per km)
+//   - formula: epley (default) or brzycki, for e1rm, best_set and intensity
+//   - bucket: day (default), week or month
+//   - smoothing: moving-average window in buckets (0 or 1 disables it)
+//   - timeRange: week, month (default), year or all
 func GetExerciseProgress(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
 		// Get user ID from token
-		userID, exists := c.Get("userID")
+		userID, exists := c.Get("user_id")
 		if !exists {
 			utils.ErrorResponse(c, http.StatusUnauthorized, "User not authenticated", nil)
 			return
@@ -134,64 +174,240 @@ func GetExerciseProgress(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
+		// The exercise's tracking type decides which metrics make sense
+		var exercise models.Exercise
+		if err := visibleExercises(db.Model(&models.Exercise{}), userID).
+			Where("id = ?", exerciseID).
+			First(&exercise).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				utils.ErrorResponse(c, http.StatusNotFound, "Exercise not found", nil)
+			} else {
+				utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch exercise", err)
+			}
+			return
+		}
+
+		metrics, ok := progressMetrics[exercise.TrackingType]
+		if !ok {
+			metrics = progressMetrics[models.TrackingWeightReps]
+		}
+		metric := c.DefaultQuery("metric", metrics[0])
+		if !slices.Contains(metrics, metric) {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid metric for this exercise", nil)
+			return
+		}
+
+		formula := c.DefaultQuery("formula", utils.FormulaEpley)
+		if formula != utils.FormulaEpley && formula != utils.FormulaBrzycki {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid formula", nil)
+			return
+		}
+
+		bucket := c.DefaultQuery("bucket", utils.BucketDay)
+		if bucket != utils.BucketDay && bucket != utils.BucketWeek && bucket != utils.BucketMonth {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid bucket", nil)
+			return
+		}
+
+		smoothing, err := strconv.Atoi(c.DefaultQuery("smoothing", "0"))
+		if err != nil || smoothing < 0 || smoothing > maxSmoothingWindow {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid smoothing window", nil)
+			return
+		}
+
+		// Weights are stored in kg and reported in the user's preferred unit
+		preferredUnit, appErr := preferredWeightUnit(db, userID)
+		if appErr != nil {
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
+		// Sets are bucketed by the day they were performed in the user's timezone
+		loc, appErr := preferredLocation(db, userID)
+		if appErr != nil {
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
 		// Get time range from query params (default to month)
 		timeRange := c.DefaultQuery("timeRange", "month")
 
 		// Determine the start date based on time range
 		var startDate time.Time
-		now := time.Now()
+		now := time.Now().In(loc)
 		
 		switch timeRange {
 		case "week":
 			startDate = now.AddDate(0, 0, -7)
 		case "year":
 			startDate = now.AddDate(-1, 0, 0)
+		case "all":
+			startDate = time.Time{}
 		default: // month
 			startDate = now.AddDate(0, -1, 0)
 		}
 
-		// Query to get exercise data
-		type ExerciseData struct {
-			Date   time.Time
-			Weight float64
+		// Fetch the user's sets of this exercise, oldest first
+		type ExerciseSet struct {
+			Date            time.Time
+			Reps            int
+			Weight          float64
+			DurationSeconds *int
+			DistanceMeters  *float64
 		}
-		var results []ExerciseData
+		var sets []ExerciseSet
 
-		// Query to get the max weight for each date for the specified exercise
-		query := db.Table("workout_exercises").
-			Select("DATE(workout_exercises.created_at) as date, MAX(workout_exercises.weight) as weight").
-			Joins("JOIN workouts ON workout_exercises.workout_id = workouts.id").
-			Where("workouts.user_id = ? AND workout_exercises.exercise_id = ? AND workout_exercises.created_at >= ?", 
+		query := db.Table("workout_entries").
+			Select("workouts.performed_at as date, workout_entries.reps, workout_entries.weight, "+
+				"workout_entries.duration_seconds, workout_entries.distance_meters").
+			Joins("JOIN workouts ON workout_entries.workout_id = workouts.id AND workouts.deleted_at IS NULL").
+			Where("workout_entries.deleted_at IS NULL AND workouts.user_id = ? AND workout_entries.exercise_id = ? AND workouts.performed_at >= ?",
 				userID, exerciseID, startDate).
-			Group("DATE(workout_exercises.created_at)").
-			Order("date")
-		
-		if err := query.Find(&results).Error; err != nil {
+			// warm-ups would drag down every metric
+			Where("workout_entries.set_type <> ?", models.SetTypeWarmup).
+			Order("workouts.performed_at, workout_entries.set_number")
+
+		if err := query.Find(&sets).Error; err != nil {
 			utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch exercise progress", err)
 			return
 		}
 
+		// Aggregate the sets into buckets
+		var points []ProgressPoint
+		var intensitySum, paceSeconds, paceMeters float64
+		var bestE1RM, runningBestE1RM float64
+		finishPoint := func() {
+			if len(points) == 0 {
+				return
+			}
+			last := &points[len(points)-1]
+			switch metric {
+			case metricIntensity:
+				last.Value = intensitySum / float64(last.Sets)
+			case metricPace:
+				// seconds per kilometre over the sets that logged both
+				if paceMeters > 0 {
+					last.Value = paceSeconds / (paceMeters / 1000)
+				}
+			}
+		}
+		for _, set := range sets {
+			date := utils.BucketStart(set.Date.In(loc), bucket).Format("2006-01-02")
+			if len(points) == 0 || points[len(points)-1].Date != date {
+				finishPoint()
+				points = append(points, ProgressPoint{Date: date})
+				intensitySum, paceSeconds, paceMeters, bestE1RM = 0, 0, 0, 0
+			}
+			point := &points[len(points)-1]
+			point.Sets++
+
+			e1RM := utils.EstimateOneRepMaxWith(formula, set.Weight, set.Reps)
+			if e1RM > runningBestE1RM {
+				runningBestE1RM = e1RM
+			}
+
+			switch metric {
+			case metricMaxWeight:
+				point.Value = math.Max(point.Value, set.Weight)
+			case metricE1RM:
+				point.Value = math.Max(point.Value, e1RM)
+			case metricVolume:
+				point.Value += set.Weight * float64(set.Reps)
+			case metricReps:
+				point.Value += float64(set.Reps)
+			case metricBestSet:
+				if e1RM > bestE1RM || point.Sets == 1 {
+					bestE1RM = e1RM
+					point.Value = e1RM
+					point.Weight = set.Weight
+					point.Reps = set.Reps
+				}
+			case metricIntensity:
+				// weight as a percentage of the best estimated 1RM so far
+				if runningBestE1RM > 0 {
+					intensitySum += set.Weight / runningBestE1RM * 100
+				}
+			case metricMaxReps:
+				point.Value = math.Max(point.Value, float64(set.Reps))
+			case metricDuration:
+				point.Value += float64(intValue(set.DurationSeconds))
+			case metricMaxDuration:
+				point.Value = math.Max(point.Value, float64(intValue(set.DurationSeconds)))
+			case metricDistance:
+				point.Value += floatValue(set.DistanceMeters)
+			case metricPace:
+				if set.DurationSeconds != nil && set.DistanceMeters != nil {
+					paceSeconds += float64(*set.DurationSeconds)
+					paceMeters += *set.DistanceMeters
+				}
+			}
+		}
+		finishPoint()
+
+		// Convert the weight metrics to the user's unit
+		for i := range points {
+			switch metric {
+			case metricMaxWeight:
+				points[i].Value = utils.DisplayWeight(points[i].Value, preferredUnit)
+			case metricE1RM, metricVolume, metricBestSet:
+				points[i].Value = utils.ConvertWeight(points[i].Value, preferredUnit)
+			}
+			if points[i].Weight > 0 {
+				points[i].Weight = utils.DisplayWeight(points[i].Weight, preferredUnit)
+			}
+		}
+
 		// Prepare data for response
-		var dates []string
-		var weights []float64
+		dates := make([]string, len(points))
+		values := make([]float64, len(points))
+		for i, point := range points {
+			points[i].Value = math.Round(point.Value*100) / 100
+			dates[i] = point.Date
+			values[i] = points[i].Value
+		}
 
-		for _, r := range results {
-			dates = append(dates, r.Date.Format("2006-01-02"))
-			weights = append(weights, r.Weight)
+		metricUnit, ok := progressMetricUnits[metric]
+		if !ok {
+			metricUnit = preferredUnit
 		}
 
-		c.JSON(http.StatusOK, gin.H{
-			"dates":   dates,
-			"weights": weights,
-		})
+		response := gin.H{
+			"metric":        metric,
+			"metric_unit":   metricUnit,
+			"tracking_type": exercise.TrackingType,
+			"bucket":        bucket,
+			"timezone":      loc.String(),
+			"weight_unit":   preferredUnit,
+			"dates":         dates,
+			"values":        values,
+			"points":        points,
+		}
+
+		if metric == metricE1RM || metric == metricBestSet || metric == metricIntensity {
+			response["formula"] = formula
+		}
+
+		if metric == metricMaxWeight {
+			// kept for clients of the original weights-only response
+			response["weights"] = values
+		}
+
+		if smoothing > 1 {
+			response["smoothing"] = smoothing
+			response["smoothed"] = utils.MovingAverage(values, smoothing)
+		}
+
+		c.JSON(http.StatusOK, response)
 	}
 }
 
 // GetAggregateStats returns aggregate statistics about workouts and exercises
 func GetAggregateStats(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
 		// Get user ID from token
-		userID, exists := c.Get("userID")
+		userID, exists := c.Get("user_id")
 		if !exists {
 			utils.ErrorResponse(c, http.StatusUnauthorized, "User not authenticated", nil)
 			return
@@ -204,13 +420,14 @@ func GetAggregateStats(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
-		// Total exercises performed
+		// Total exercises performed, counting each exercise once per workout
+		// and leaving out exercises that only had warm-up sets
 		var totalExercises int64
-		if err := db.Model(&models.Exercise{}).
-			Joins("JOIN workout_exercises ON exercises.id = workout_exercises.exercise_id").
-			Joins("JOIN workouts ON workout_exercises.workout_id = workouts.id").
-			Where("workouts.user_id = ?", userID).
-			Count(&totalExercises).Error; err != nil {
+		if err := db.Table("workout_entries").
+			Select("COUNT(DISTINCT (workout_entries.workout_id, workout_entries.exercise_id))").
+			Joins("JOIN workouts ON workout_entries.workout_id = workouts.id AND workouts.deleted_at IS NULL").
+			Where("workout_entries.deleted_at IS NULL AND workouts.user_id = ? AND workout_entries.set_type <> ?", userID, models.SetTypeWarmup).
+			Scan(&totalExercises).Error; err != nil {
 			utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch exercise statistics", err)
 			return
 		}
@@ -228,6 +445,22 @@ func GetAggregateStats(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
+		// Distance and time covered by cardio and timed exercises
+		type CardioTotals struct {
+			TotalDistance float64
+			TotalDuration int64
+		}
+		var cardioTotals CardioTotals
+		if err := db.Table("workout_entries").
+			Select("COALESCE(SUM(workout_entries.distance_meters), 0) as total_distance, "+
+				"COALESCE(SUM(workout_entries.duration_seconds), 0) as total_duration").
+			Joins("JOIN workouts ON workout_entries.workout_id = workouts.id AND workouts.deleted_at IS NULL").
+			Where("workout_entries.deleted_at IS NULL AND workouts.user_id = ? AND workout_entries.set_type <> ?", userID, models.SetTypeWarmup).
+			Scan(&cardioTotals).Error; err != nil {
+			utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch cardio statistics", err)
+			return
+		}
+
 		// Get list of exercises used
 		type Exercise struct {
 			ID   string `json:"id"`
@@ -236,19 +469,80 @@ func GetAggregateStats(db *gorm.DB) gin.HandlerFunc {
 		var exercises []Exercise
 		if err := db.Model(&models.Exercise{}).
 			Select("DISTINCT exercises.id, exercises.name").
-			Joins("JOIN workout_exercises ON exercises.id = workout_exercises.exercise_id").
-			Joins("JOIN workouts ON workout_exercises.workout_id = workouts.id").
-			Where("workouts.user_id = ?", userID).
+			Joins("JOIN workout_entries ON exercises.id = workout_entries.exercise_id AND workout_entries.deleted_at IS NULL").
+			Joins("JOIN workouts ON workout_entries.workout_id = workouts.id AND workouts.deleted_at IS NULL").
+			Where("workouts.user_id = ? AND workout_entries.set_type <> ?", userID, models.SetTypeWarmup).
 			Scan(&exercises).Error; err != nil {
 			utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch exercise list", err)
 			return
 		}
 
 		c.JSON(http.StatusOK, gin.H{
-			"totalWorkouts": totalWorkouts,
-			"totalExercises": totalExercises,
-			"avgDuration":   avgDuration.AvgDuration,
-			"exercises":     exercises,
+			"totalWorkouts":        totalWorkouts,
+			"totalExercises":       totalExercises,
+			"avgDuration":          avgDuration.AvgDuration,
+			"totalDistanceMeters":  cardioTotals.TotalDistance,
+			"totalDurationSeconds": cardioTotals.TotalDuration,
+			"exercises":            exercises,
 		})
 	}
 }
+
+// GetPersonalRecords returns the user's personal records. By default only the
+// current (unbeaten) records are returned; history=true returns every record.
+func GetPersonalRecords(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
+		// extract user_id from context
+		userID, exists := c.Get("user_id")
+		if !exists {
+			appErr := utils.NewAuthenticationError("User not authenticated", nil)
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
+		query := db.Where("user_id = ?", userID)
+
+		if exerciseID := c.Query("exercise_id"); exerciseID != "" {
+			if _, err := strconv.ParseUint(exerciseID, 10, 32); err != nil {
+				appErr := utils.NewInvalidInputError("Invalid exercise ID", err)
+				utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+				return
+			}
+			query = query.Where("exercise_id = ?", exerciseID)
+		}
+
+		if recordType := c.Query("type"); recordType != "" {
+			switch recordType {
+			case models.RecordMaxWeight, models.RecordRepsAtWeight, models.RecordEstimated1RM, models.RecordSessionVolume:
+				query = query.Where("type = ?", recordType)
+			default:
+				appErr := utils.NewInvalidInputError("Invalid record type", nil)
+				utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+				return
+			}
+		}
+
+		if c.Query("history") != "true" {
+			query = query.Where("is_current = ?", true)
+		}
+
+		preferredUnit, appErr := preferredWeightUnit(db, userID)
+		if appErr != nil {
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
+		var records []models.PersonalRecord
+		if err := query.Preload("Exercise").
+			Order("achieved_at DESC, id DESC").
+			Find(&records).Error; err != nil {
+			appErr := utils.NewDatabaseError("Failed to fetch personal records", err)
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
+		utils.SuccessResponse(c, "Personal records retrieved successfully", toPersonalRecordResponses(records, preferredUnit))
+	}
+}
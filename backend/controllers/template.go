@@ -2,7 +2,6 @@ package controllers
 
 import (
 	"errors"
-	"net/http"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
@@ -12,15 +11,48 @@ import (
 	"github.com/rachitnimje/trackle-web/utils"
 )
 
+// CreateTemplateRequest creates a template. Target weights are in Unit, or in
+// the user's preferred unit when it is omitted.
 type CreateTemplateRequest struct {
 	Name        string                          `json:"name" binding:"required"`
 	Description string                          `json:"description" binding:"required"`
-	Exercises   []CreateTemplateExerciseRequest `json:"exercises" binding:"required,min=1"`
+	Unit        string                          `json:"unit" binding:"omitempty,oneof=kg lb"`
+	Exercises   []CreateTemplateExerciseRequest `json:"exercises" binding:"required,min=1,dive"`
 }
 
+// CreateTemplateExerciseRequest describes one exercise of a template. Exercises
+// are ordered as they appear in the request. Either a set count or per-set
+// targets must be given; when targets are given they define the set count.
+// Progression is optional and only applies to weight and reps exercises.
+// Grouped exercises default to one set per round of their group.
 type CreateTemplateExerciseRequest struct {
-	ExerciseID uint `json:"exercise_id" binding:"required"`
-	Sets       int  `json:"sets" binding:"required,min=1"`
+	ExerciseID  uint                  `json:"exercise_id" binding:"required"`
+	Sets        int                   `json:"sets" binding:"omitempty,min=1"`
+	SetTargets  []TemplateSetRequest  `json:"set_targets" binding:"omitempty,dive"`
+	Progression *ProgressionRequest   `json:"progression"`
+	Group       *ExerciseGroupRequest `json:"group"`
+}
+
+type TemplateSetRequest struct {
+	TargetReps   *int     `json:"target_reps" binding:"omitempty,min=1"`
+	TargetWeight *float64 `json:"target_weight" binding:"omitempty,min=0"`
+	RestSeconds  *int     `json:"rest_seconds" binding:"omitempty,min=0"`
+}
+
+type UpdateTemplateRequest struct {
+	Name        string                          `json:"name" binding:"required"`
+	Description string                          `json:"description" binding:"required"`
+	Unit        string                          `json:"unit" binding:"omitempty,oneof=kg lb"`
+	Exercises   []CreateTemplateExerciseRequest `json:"exercises" binding:"required,min=1,dive"`
+}
+
+// PatchTemplateRequest only changes the fields that are present. When
+// exercises are present they replace the template's exercise list.
+type PatchTemplateRequest struct {
+	Name        *string                         `json:"name" binding:"omitempty,min=1"`
+	Description *string                         `json:"description"`
+	Unit        string                          `json:"unit" binding:"omitempty,oneof=kg lb"`
+	Exercises   []CreateTemplateExerciseRequest `json:"exercises" binding:"omitempty,min=1,dive"`
 }
 
 type GetAllTemplatesResponse struct {
@@ -29,8 +61,11 @@ type GetAllTemplatesResponse struct {
 	UpdatedAt   string `json:"updated_at"`
 	Name        string `json:"name"`
 	Description string `json:"description"`
+	Visibility  string `json:"visibility"`
 }
 
+// TemplateResponse is a template with its exercises. Source names the shared
+// template it was imported from, if any.
 type TemplateResponse struct {
 	ID          string                     `json:"id"`
 	CreatedAt   string                     `json:"created_at"`
@@ -38,19 +73,43 @@ type TemplateResponse struct {
 	Name        string                     `json:"name"`
 	Description string                     `json:"description"`
 	UserID      uint                       `json:"user_id"`
+	Visibility  string                     `json:"visibility"`
+	ShareToken  *string                    `json:"share_token"`
+	Source      *TemplateSourceResponse    `json:"source"`
+	WeightUnit  string                     `json:"weight_unit"`
 	Exercises   []TemplateExerciseResponse `json:"exercises"`
+	Groups      []ExerciseGroupResponse    `json:"groups"`
+}
+
+type TemplateSourceResponse struct {
+	TemplateID uint   `json:"template_id"`
+	Name       string `json:"name"`
+	Author     string `json:"author"`
 }
 
 type TemplateExerciseResponse struct {
-	ExerciseID  uint   `json:"exercise_id"`
-	Sets        int    `json:"sets"`
-	Name        string `json:"name"`
-	Description string `json:"description"`
-	Category    string `json:"category"`
+	ExerciseID  uint                  `json:"exercise_id"`
+	Position    int                   `json:"position"`
+	Sets        int                   `json:"sets"`
+	SetTargets  []TemplateSetResponse `json:"set_targets"`
+	Progression *ProgressionResponse  `json:"progression"`
+	GroupID     *int                  `json:"group_id"`
+	Name        string                `json:"name"`
+	Description string                `json:"description"`
+	Category    string                `json:"category"`
+}
+
+type TemplateSetResponse struct {
+	SetNumber    int      `json:"set_number"`
+	TargetReps   *int     `json:"target_reps"`
+	TargetWeight *float64 `json:"target_weight"`
+	RestSeconds  *int     `json:"rest_seconds"`
 }
 
 func CreateUserTemplate(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
 		// extract the user_id from context
 		userID, exists := c.Get("user_id")
 		if !exists {
@@ -69,35 +128,23 @@ func CreateUserTemplate(db *gorm.DB) gin.HandlerFunc {
 
 		// TODO: check for duplicate names in the existing table
 
-		// get the non-duplicate exercise ids from request
-		exerciseIDMap := make(map[uint]bool)
-		var exerciseIDs []uint
-		for _, e := range req.Exercises {
-			if exerciseIDMap[e.ExerciseID] {
-				utils.ErrorResponse(c, http.StatusBadRequest, "Duplicate exercise IDs not allowed", nil)
-				return
-			}
-			exerciseIDMap[e.ExerciseID] = true
-			exerciseIDs = append(exerciseIDs, e.ExerciseID)
-		}
-
-		// verify all exercises exist
-		var exerciseCount int64
-		if err := db.Model(&models.Exercise{}).Where("id IN ?", exerciseIDs).Count(&exerciseCount).Error; err != nil {
-			appErr := utils.NewDatabaseError("Failed to verify exercises", err)
+		// validate the exercises
+		if appErr := validateTemplateExercises(db, userID, req.Exercises); appErr != nil {
 			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
 			return
 		}
 
-		if int(exerciseCount) != len(exerciseIDs) {
-			appErr := utils.NewInvalidInputError("One or more exercise IDs are invalid", nil)
+		// target weights are stored in kg and returned in the user's preferred unit
+		preferredUnit, appErr := preferredWeightUnit(db, userID)
+		if appErr != nil {
 			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
 			return
 		}
+		inputUnit := requestWeightUnit(req.Unit, preferredUnit)
 
 		// transaction manager for atomic operation
 		var createdTemplateID uint
-		utils.TransactionManager(db, c, func(tx *gorm.DB) error {
+		err := utils.TransactionManager(db, c, func(tx *gorm.DB) error {
 			// create template
 			template := models.Template{
 				Name:        req.Name,
@@ -106,48 +153,46 @@ func CreateUserTemplate(db *gorm.DB) gin.HandlerFunc {
 			}
 
 			if err := tx.Create(&template).Error; err != nil {
-				return utils.NewDatabaseError("Failed to create template", err)
+				appErr = utils.NewDatabaseError("Failed to create template", err)
+				return appErr
 			}
 
-			createdTemplateID = template.ID
-
-			// create template exercises for batch insert
-			var templateExercises []models.TemplateExercise
-			for _, e := range req.Exercises {
-				templateExercise := models.TemplateExercise{
-					TemplateID: template.ID,
-					ExerciseID: e.ExerciseID,
-					Sets:       e.Sets,
+			// create template exercises and their set targets
+			if err := syncTemplateExercises(tx, template.ID, req.Exercises, inputUnit); err != nil {
+				if !errors.As(err, &appErr) {
+					appErr = utils.NewDatabaseError("Failed to create template exercises", err)
 				}
-				templateExercises = append(templateExercises, templateExercise)
-			}
-
-			// batch create template exercises
-			if err := tx.Create(&templateExercises).Error; err != nil {
-				return utils.NewDatabaseError("Failed to create template exercise", err)
+				return appErr
 			}
 
+			createdTemplateID = template.ID
 			return nil
 		})
 
-		// Check if we need to reload template data for response
-		if createdTemplateID > 0 {
-			var template models.Template
-			if err := db.Preload("Exercises.Exercise").First(&template, createdTemplateID).Error; err != nil {
-				appErr := utils.NewDatabaseError("Failed to load template", err)
-				utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
-				return
-			}
+		if appErr != nil {
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+		if err != nil {
+			// the failed commit has been written
+			return
+		}
 
-			utils.CreatedResponse(c, "Template created successfully", template)
-		} else {
-			utils.CreatedResponse(c, "Template created successfully", nil)
+		template, err := loadUserTemplate(db, createdTemplateID, userID)
+		if err != nil {
+			appErr := utils.NewDatabaseError("Failed to load template", err)
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
 		}
+
+		utils.CreatedResponse(c, "Template created successfully", toTemplateResponse(template, preferredUnit))
 	}
 }
 
 func GetAllUserTemplates(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
 		//extract the user_id from context
 		userID, exists := c.Get("user_id")
 		if !exists {
@@ -214,6 +259,7 @@ func GetAllUserTemplates(db *gorm.DB) gin.HandlerFunc {
 				UpdatedAt:   template.UpdatedAt.Format("2006-01-02T15:04:05Z07:00"),
 				Name:        template.Name,
 				Description: template.Description,
+				Visibility:  template.Visibility,
 			})
 		}
 
@@ -223,6 +269,8 @@ func GetAllUserTemplates(db *gorm.DB) gin.HandlerFunc {
 
 func GetUserTemplate(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
 		// extract the user_id from context
 		userID, exists := c.Get("user_id")
 		if !exists {
@@ -246,11 +294,8 @@ func GetUserTemplate(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
-		var template models.Template
-
-		if err := db.Where("id = ? AND user_id = ?", templateIDStr, userID).
-			Preload("Exercises.Exercise").
-			First(&template).Error; err != nil {
+		template, err := loadUserTemplate(db, uint(templateID), userID)
+		if err != nil {
 			if errors.Is(err, gorm.ErrRecordNotFound) {
 				appErr := utils.NewNotFoundError("Template not found", nil)
 				utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
@@ -261,32 +306,178 @@ func GetUserTemplate(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
-		response := TemplateResponse{
-			ID:          strconv.Itoa(int(template.ID)),
-			CreatedAt:   template.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
-			UpdatedAt:   template.UpdatedAt.Format("2006-01-02T15:04:05Z07:00"),
-			Name:        template.Name,
-			Description: template.Description,
-			UserID:      template.UserID,
-			Exercises:   make([]TemplateExerciseResponse, len(template.Exercises)),
+		preferredUnit, appErr := preferredWeightUnit(db, userID)
+		if appErr != nil {
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
+		response := toTemplateResponse(template, preferredUnit)
+
+		utils.SuccessResponse(c, "Template retrieved successfully", response)
+	}
+}
+
+func UpdateUserTemplate(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
+		// bind and validate request
+		var req UpdateTemplateRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			appErr := utils.NewValidationError("Invalid request data", err)
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
+		// a full update is a patch with every field present
+		applyTemplateUpdate(db, c, PatchTemplateRequest{
+			Name:        &req.Name,
+			Description: &req.Description,
+			Unit:        req.Unit,
+			Exercises:   req.Exercises,
+		})
+	}
+}
+
+func PatchUserTemplate(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
+		// bind and validate request
+		var req PatchTemplateRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			appErr := utils.NewValidationError("Invalid request data", err)
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
+		applyTemplateUpdate(db, c, req)
+	}
+}
+
+// applyTemplateUpdate updates a template in place, so workouts logged against it
+// keep a valid TemplateID
+func applyTemplateUpdate(db *gorm.DB, c *gin.Context, req PatchTemplateRequest) {
+	// extract the user_id from context
+	userID, exists := c.Get("user_id")
+	if !exists {
+		appErr := utils.NewAuthenticationError("User not authenticated", nil)
+		utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+		return
+	}
+
+	// parse the template ID with validation
+	templateIDStr := c.Param("id")
+	if templateIDStr == "" {
+		appErr := utils.NewInvalidInputError("Template ID is required", nil)
+		utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+		return
+	}
+
+	templateID, err := strconv.ParseUint(templateIDStr, 10, 32)
+	if err != nil || templateID == 0 {
+		appErr := utils.NewInvalidInputError("Invalid template ID", err)
+		utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+		return
+	}
+
+	// check if template exists and belongs to user
+	var template models.Template
+	if err := db.Where("id = ? AND user_id = ?", templateID, userID).First(&template).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			appErr := utils.NewNotFoundError("Template not found", nil)
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+		} else {
+			appErr := utils.NewDatabaseError("Failed to find template", err)
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+		}
+		return
+	}
+
+	if len(req.Exercises) > 0 {
+		if appErr := validateTemplateExercises(db, userID, req.Exercises); appErr != nil {
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
+		// others cannot see custom exercises, so a shared template cannot use them
+		if template.Visibility != models.VisibilityPrivate {
+			exerciseIDs := make([]uint, len(req.Exercises))
+			for i, e := range req.Exercises {
+				exerciseIDs[i] = e.ExerciseID
+			}
+			custom, err := hasCustomExercises(db, exerciseIDs)
+			if err != nil {
+				appErr := utils.NewDatabaseError("Failed to verify exercises", err)
+				utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+				return
+			}
+			if custom {
+				appErr := utils.NewInvalidInputError("Shared templates cannot use custom exercises", nil)
+				utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+				return
+			}
+		}
+	}
+
+	// target weights are stored in kg and returned in the user's preferred unit
+	preferredUnit, appErr := preferredWeightUnit(db, userID)
+	if appErr != nil {
+		utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+		return
+	}
+	inputUnit := requestWeightUnit(req.Unit, preferredUnit)
+
+	// transaction manager for atomic operation
+	err = utils.TransactionManager(db, c, func(tx *gorm.DB) error {
+		if req.Name != nil {
+			template.Name = *req.Name
+		}
+		if req.Description != nil {
+			template.Description = *req.Description
+		}
+
+		if err := tx.Save(&template).Error; err != nil {
+			appErr = utils.NewDatabaseError("Failed to update template", err)
+			return appErr
 		}
 
-		for i, templateExercise := range template.Exercises {
-			response.Exercises[i] = TemplateExerciseResponse{
-				ExerciseID:  templateExercise.ExerciseID,
-				Sets:        templateExercise.Sets,
-				Name:        templateExercise.Exercise.Name,
-				Description: templateExercise.Exercise.Description,
-				Category:    templateExercise.Exercise.Category,
+		if len(req.Exercises) > 0 {
+			if err := syncTemplateExercises(tx, template.ID, req.Exercises, inputUnit); err != nil {
+				if !errors.As(err, &appErr) {
+					appErr = utils.NewDatabaseError("Failed to update template exercises", err)
+				}
+				return appErr
 			}
 		}
 
-		utils.SuccessResponse(c, "Template retrieved successfully", response)
+		return nil
+	})
+
+	if appErr != nil {
+		utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+		return
+	}
+	if err != nil {
+		// the failed commit has been written
+		return
+	}
+
+	template, err = loadUserTemplate(db, template.ID, userID)
+	if err != nil {
+		appErr := utils.NewDatabaseError("Failed to load template", err)
+		utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+		return
 	}
+
+	utils.SuccessResponse(c, "Template updated successfully", toTemplateResponse(template, preferredUnit))
 }
 
 func DeleteUserTemplate(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
 		// extract the user_id from context
 		userID, exists := c.Get("user_id")
 		if !exists {
@@ -323,20 +514,259 @@ func DeleteUserTemplate(db *gorm.DB) gin.HandlerFunc {
 		}
 
 		// transaction manager to handle the deletion atomically
-		utils.TransactionManager(db, c, func(tx *gorm.DB) error {
-			// First delete template exercises
+		var appErr *utils.AppError
+		err = utils.TransactionManager(db, c, func(tx *gorm.DB) error {
+			// First delete the set targets and template exercises
+			if err := tx.Where("template_exercise_id IN (?)",
+				tx.Model(&models.TemplateExercise{}).Select("id").Where("template_id = ?", templateID),
+			).Delete(&models.TemplateSet{}).Error; err != nil {
+				appErr = utils.NewDatabaseError("Failed to delete template sets", err)
+				return appErr
+			}
+
 			if err := tx.Where("template_id = ?", templateID).Delete(&models.TemplateExercise{}).Error; err != nil {
-				return utils.NewDatabaseError("Failed to delete template exercises", err)
+				appErr = utils.NewDatabaseError("Failed to delete template exercises", err)
+				return appErr
 			}
 
 			// Then delete the template
 			if err := tx.Delete(&template).Error; err != nil {
-				return utils.NewDatabaseError("Failed to delete template", err)
+				appErr = utils.NewDatabaseError("Failed to delete template", err)
+				return appErr
 			}
 
 			return nil
 		})
 
+		if appErr != nil {
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+		if err != nil {
+			// the failed commit has been written
+			return
+		}
+
 		utils.SuccessResponse(c, "Template deleted successfully", nil)
 	}
 }
+
+// validateTemplateExercises checks that the exercises are unique, visible to the
+// user and have a consistent set count, a usable progression rule and
+// well-formed groups
+func validateTemplateExercises(db *gorm.DB, userID interface{}, exercises []CreateTemplateExerciseRequest) *utils.AppError {
+	if appErr := validateTemplateGroups(exercises); appErr != nil {
+		return appErr
+	}
+
+	// get the non-duplicate exercise ids from request
+	exerciseIDMap := make(map[uint]bool)
+	var exerciseIDs []uint
+	for _, e := range exercises {
+		if exerciseIDMap[e.ExerciseID] {
+			return utils.NewInvalidInputError("Duplicate exercise IDs not allowed", nil)
+		}
+		exerciseIDMap[e.ExerciseID] = true
+		exerciseIDs = append(exerciseIDs, e.ExerciseID)
+
+		if e.Sets == 0 && len(e.SetTargets) == 0 && e.Group == nil {
+			return utils.NewInvalidInputError("Each exercise needs a set count or set targets", nil)
+		}
+		if e.Sets != 0 && len(e.SetTargets) != 0 && e.Sets != len(e.SetTargets) {
+			return utils.NewInvalidInputError("Set count does not match the number of set targets", nil)
+		}
+	}
+
+	// verify all exercises exist and are visible to the user
+	var found []models.Exercise
+	if err := visibleExercises(db.Model(&models.Exercise{}), userID).Where("id IN ?", exerciseIDs).Find(&found).Error; err != nil {
+		return utils.NewDatabaseError("Failed to verify exercises", err)
+	}
+
+	if len(found) != len(exerciseIDs) {
+		return utils.NewInvalidInputError("One or more exercise IDs are invalid", nil)
+	}
+
+	exercisesByID := make(map[uint]models.Exercise, len(found))
+	for _, exercise := range found {
+		exercisesByID[exercise.ID] = exercise
+	}
+	for _, e := range exercises {
+		if appErr := validateProgression(exercisesByID[e.ExerciseID], e); appErr != nil {
+			return appErr
+		}
+	}
+
+	return nil
+}
+
+// syncTemplateExercises makes the template's exercises match the request, in
+// request order. Rows for exercises that stay in the template are updated in
+// place; their set targets, progression rules and groups are replaced. Target
+// weights and progression increments are given in unit.
+func syncTemplateExercises(tx *gorm.DB, templateID uint, exercises []CreateTemplateExerciseRequest, unit string) error {
+	var existing []models.TemplateExercise
+	if err := tx.Where("template_id = ?", templateID).Find(&existing).Error; err != nil {
+		return utils.NewDatabaseError("Failed to load template exercises", err)
+	}
+
+	existingByExercise := make(map[uint]models.TemplateExercise)
+	for _, e := range existing {
+		existingByExercise[e.ExerciseID] = e
+	}
+
+	keep := make(map[uint]bool)
+	for position, e := range exercises {
+		sets := e.Sets
+		if len(e.SetTargets) > 0 {
+			sets = len(e.SetTargets)
+		}
+		if sets == 0 && e.Group != nil {
+			sets = e.Group.Rounds
+		}
+
+		templateExercise, found := existingByExercise[e.ExerciseID]
+		if !found {
+			templateExercise = models.TemplateExercise{
+				TemplateID: templateID,
+				ExerciseID: e.ExerciseID,
+			}
+		}
+		templateExercise.Position = position
+		templateExercise.Sets = sets
+		applyProgression(&templateExercise, e.Progression, unit)
+		applyGroup(&templateExercise, e.Group)
+
+		if err := tx.Omit("Template", "Exercise", "SetTargets").Save(&templateExercise).Error; err != nil {
+			return utils.NewDatabaseError("Failed to save template exercise", err)
+		}
+		keep[templateExercise.ID] = true
+
+		// replace the set targets
+		if err := tx.Unscoped().Where("template_exercise_id = ?", templateExercise.ID).Delete(&models.TemplateSet{}).Error; err != nil {
+			return utils.NewDatabaseError("Failed to delete template sets", err)
+		}
+
+		if len(e.SetTargets) > 0 {
+			var templateSets []models.TemplateSet
+			for i, target := range e.SetTargets {
+				var targetWeight *float64
+				if target.TargetWeight != nil {
+					weight := utils.ToKg(*target.TargetWeight, unit)
+					targetWeight = &weight
+				}
+
+				templateSets = append(templateSets, models.TemplateSet{
+					TemplateExerciseID: templateExercise.ID,
+					SetNumber:          i + 1,
+					TargetReps:         target.TargetReps,
+					TargetWeight:       targetWeight,
+					RestSeconds:        target.RestSeconds,
+				})
+			}
+
+			if err := tx.Create(&templateSets).Error; err != nil {
+				return utils.NewDatabaseError("Failed to create template sets", err)
+			}
+		}
+	}
+
+	// remove exercises that are no longer part of the template
+	for _, e := range existing {
+		if keep[e.ID] {
+			continue
+		}
+		if err := tx.Where("template_exercise_id = ?", e.ID).Delete(&models.TemplateSet{}).Error; err != nil {
+			return utils.NewDatabaseError("Failed to delete template sets", err)
+		}
+		if err := tx.Delete(&e).Error; err != nil {
+			return utils.NewDatabaseError("Failed to delete template exercise", err)
+		}
+	}
+
+	return nil
+}
+
+// loadUserTemplate loads a user's template with its exercises and set targets in order
+func loadUserTemplate(db *gorm.DB, templateID uint, userID interface{}) (models.Template, error) {
+	var template models.Template
+	err := preloadTemplateDetails(db).
+		Where("id = ? AND user_id = ?", templateID, userID).
+		First(&template).Error
+	return template, err
+}
+
+// preloadTemplateDetails preloads what toTemplateResponse needs: the exercises
+// and set targets in order, and the source of an imported template even when
+// it has since been deleted
+func preloadTemplateDetails(db *gorm.DB) *gorm.DB {
+	return db.
+		Preload("Exercises", func(db *gorm.DB) *gorm.DB {
+			return db.Order("position, id")
+		}).
+		Preload("Exercises.Exercise").
+		Preload("Exercises.SetTargets", func(db *gorm.DB) *gorm.DB {
+			return db.Order("set_number")
+		}).
+		Preload("Source", func(db *gorm.DB) *gorm.DB {
+			return db.Unscoped()
+		}).
+		Preload("Source.User")
+}
+
+// toTemplateResponse maps a template loaded by loadUserTemplate to its DTO,
+// with target weights in unit
+func toTemplateResponse(template models.Template, unit string) TemplateResponse {
+	response := TemplateResponse{
+		ID:          strconv.Itoa(int(template.ID)),
+		CreatedAt:   template.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
+		UpdatedAt:   template.UpdatedAt.Format("2006-01-02T15:04:05Z07:00"),
+		Name:        template.Name,
+		Description: template.Description,
+		UserID:      template.UserID,
+		Visibility:  template.Visibility,
+		ShareToken:  template.ShareToken,
+		WeightUnit:  unit,
+		Exercises:   make([]TemplateExerciseResponse, len(template.Exercises)),
+		Groups:      templateGroups(template.Exercises),
+	}
+	if template.Source != nil {
+		response.Source = &TemplateSourceResponse{
+			TemplateID: template.Source.ID,
+			Name:       template.Source.Name,
+			Author:     template.Source.User.Username,
+		}
+	}
+
+	for i, templateExercise := range template.Exercises {
+		setTargets := make([]TemplateSetResponse, len(templateExercise.SetTargets))
+		for j, target := range templateExercise.SetTargets {
+			var targetWeight *float64
+			if target.TargetWeight != nil {
+				weight := utils.DisplayWeight(*target.TargetWeight, unit)
+				targetWeight = &weight
+			}
+
+			setTargets[j] = TemplateSetResponse{
+				SetNumber:    target.SetNumber,
+				TargetReps:   target.TargetReps,
+				TargetWeight: targetWeight,
+				RestSeconds:  target.RestSeconds,
+			}
+		}
+
+		response.Exercises[i] = TemplateExerciseResponse{
+			ExerciseID:  templateExercise.ExerciseID,
+			Position:    templateExercise.Position,
+			Sets:        templateExercise.Sets,
+			SetTargets:  setTargets,
+			Progression: toProgressionResponse(templateExercise, unit),
+			GroupID:     templateExercise.GroupID,
+			Name:        templateExercise.Exercise.Name,
+			Description: templateExercise.Exercise.Description,
+			Category:    templateExercise.Exercise.Category,
+		}
+	}
+
+	return response
+}
@@ -0,0 +1,343 @@
+package controllers
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/rachitnimje/trackle-web/models"
+	"github.com/rachitnimje/trackle-web/utils"
+)
+
+// CloneTemplateRequest names the copy of a template. The name defaults to the
+// original's followed by "(copy)".
+type CloneTemplateRequest struct {
+	Name        string  `json:"name"`
+	Description *string `json:"description"`
+}
+
+// SaveWorkoutAsTemplateRequest names the template made from a workout. The
+// name defaults to the workout's.
+type SaveWorkoutAsTemplateRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// CloneUserTemplate copies one of the user's templates with its exercises, set
+// targets, progression rules and groups under a new name
+func CloneUserTemplate(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
+		// extract the user_id from context
+		userID, exists := c.Get("user_id")
+		if !exists {
+			appErr := utils.NewAuthenticationError("User not authenticated", nil)
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
+		// parse the template ID with validation
+		templateID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil || templateID == 0 {
+			appErr := utils.NewInvalidInputError("Invalid template ID", err)
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
+		// the body is optional
+		var req CloneTemplateRequest
+		if c.Request.ContentLength != 0 {
+			if err := c.ShouldBindJSON(&req); err != nil {
+				appErr := utils.NewValidationError("Invalid request data", err)
+				utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+				return
+			}
+		}
+
+		original, err := loadUserTemplate(db, uint(templateID), userID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				appErr := utils.NewNotFoundError("Template not found", nil)
+				utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			} else {
+				appErr := utils.NewDatabaseError("Failed to fetch template", err)
+				utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			}
+			return
+		}
+
+		clone := models.Template{
+			Name:        req.Name,
+			Description: original.Description,
+			UserID:      userID.(uint),
+			Exercises:   copyTemplateExercises(original.Exercises),
+		}
+		if clone.Name == "" {
+			clone.Name = original.Name + " (copy)"
+		}
+		if req.Description != nil {
+			clone.Description = *req.Description
+		}
+
+		var appErr *utils.AppError
+		err = utils.TransactionManager(db, c, func(tx *gorm.DB) error {
+			// the exercises and set targets are created with the template
+			if err := tx.Create(&clone).Error; err != nil {
+				appErr = utils.NewDatabaseError("Failed to clone template", err)
+				return appErr
+			}
+			return nil
+		})
+
+		if appErr != nil {
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+		if err != nil {
+			// the failed commit has been written
+			return
+		}
+
+		respondWithNewTemplate(db, c, clone.ID, userID, "Template cloned successfully")
+	}
+}
+
+// SaveWorkoutAsTemplate creates a template from one of the user's workouts: its
+// exercises in the order they were first logged, with one set per working set
+// and the logged reps and weights as set targets. Groups the workout's sets
+// were logged in are kept when they fit a template.
+func SaveWorkoutAsTemplate(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
+		// extract the user_id from context
+		userID, exists := c.Get("user_id")
+		if !exists {
+			appErr := utils.NewAuthenticationError("User not authenticated", nil)
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
+		// parse the workout ID with validation
+		workoutID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil || workoutID == 0 {
+			appErr := utils.NewInvalidInputError("Invalid workout ID", err)
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
+		// the body is optional
+		var req SaveWorkoutAsTemplateRequest
+		if c.Request.ContentLength != 0 {
+			if err := c.ShouldBindJSON(&req); err != nil {
+				appErr := utils.NewValidationError("Invalid request data", err)
+				utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+				return
+			}
+		}
+
+		var workout models.Workout
+		if err := db.Where("id = ? AND user_id = ?", workoutID, userID).First(&workout).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				appErr := utils.NewNotFoundError("Workout not found", nil)
+				utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			} else {
+				appErr := utils.NewDatabaseError("Failed to fetch workout", err)
+				utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			}
+			return
+		}
+
+		var entries []models.WorkoutEntry
+		if err := db.Where("workout_id = ?", workout.ID).Order("id").Find(&entries).Error; err != nil {
+			appErr := utils.NewDatabaseError("Failed to fetch workout entries", err)
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+		if len(entries) == 0 {
+			appErr := utils.NewInvalidInputError("Workout has no sets to save", nil)
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
+		exercises := templateExercisesFromEntries(entries)
+		// sets logged in a group with uneven set counts do not make a template group
+		if validateTemplateGroups(exercises) != nil {
+			for i := range exercises {
+				exercises[i].Group = nil
+			}
+		}
+		if appErr := validateTemplateExercises(db, userID, exercises); appErr != nil {
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
+		name := req.Name
+		if name == "" {
+			name = workout.Name
+		}
+
+		var templateID uint
+		var appErr *utils.AppError
+		err = utils.TransactionManager(db, c, func(tx *gorm.DB) error {
+			template := models.Template{
+				Name:        name,
+				Description: req.Description,
+				UserID:      userID.(uint),
+			}
+
+			if err := tx.Create(&template).Error; err != nil {
+				appErr = utils.NewDatabaseError("Failed to create template", err)
+				return appErr
+			}
+
+			// logged weights are already in kg
+			if err := syncTemplateExercises(tx, template.ID, exercises, utils.UnitKg); err != nil {
+				if !errors.As(err, &appErr) {
+					appErr = utils.NewDatabaseError("Failed to create template exercises", err)
+				}
+				return appErr
+			}
+
+			templateID = template.ID
+			return nil
+		})
+
+		if appErr != nil {
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+		if err != nil {
+			// the failed commit has been written
+			return
+		}
+
+		respondWithNewTemplate(db, c, templateID, userID, "Template created successfully")
+	}
+}
+
+// copyTemplateExercises copies template exercises loaded by loadUserTemplate,
+// with their set targets, as new rows
+func copyTemplateExercises(exercises []models.TemplateExercise) []models.TemplateExercise {
+	copies := make([]models.TemplateExercise, len(exercises))
+	for i, e := range exercises {
+		copies[i] = models.TemplateExercise{
+			ExerciseID:           e.ExerciseID,
+			Position:             e.Position,
+			Sets:                 e.Sets,
+			ProgressionType:      e.ProgressionType,
+			ProgressionIncrement: e.ProgressionIncrement,
+			RepRangeMin:          e.RepRangeMin,
+			RepRangeMax:          e.RepRangeMax,
+			DeloadAfter:          e.DeloadAfter,
+			DeloadPercent:        e.DeloadPercent,
+			GroupID:              e.GroupID,
+			GroupType:            e.GroupType,
+			GroupRounds:          e.GroupRounds,
+		}
+		for _, target := range e.SetTargets {
+			copies[i].SetTargets = append(copies[i].SetTargets, models.TemplateSet{
+				SetNumber:    target.SetNumber,
+				TargetReps:   target.TargetReps,
+				TargetWeight: target.TargetWeight,
+				RestSeconds:  target.RestSeconds,
+			})
+		}
+	}
+	return copies
+}
+
+// templateExercisesFromEntries describes the exercises of a workout's entries,
+// ordered by id, as template exercises with weights in kg. Warm-up sets are
+// left out unless an exercise has nothing else.
+func templateExercisesFromEntries(entries []models.WorkoutEntry) []CreateTemplateExerciseRequest {
+	var order []uint
+	sets := make(map[uint][]models.WorkoutEntry)
+	for _, entry := range entries {
+		if _, ok := sets[entry.ExerciseID]; !ok {
+			order = append(order, entry.ExerciseID)
+		}
+		sets[entry.ExerciseID] = append(sets[entry.ExerciseID], entry)
+	}
+
+	exercises := make([]CreateTemplateExerciseRequest, 0, len(order))
+	for _, exerciseID := range order {
+		logged := sets[exerciseID]
+		var working []models.WorkoutEntry
+		for _, set := range logged {
+			if set.SetType != models.SetTypeWarmup {
+				working = append(working, set)
+			}
+		}
+		if len(working) == 0 {
+			working = logged
+		}
+		sort.SliceStable(working, func(i, j int) bool {
+			return working[i].SetNumber < working[j].SetNumber
+		})
+
+		exercise := CreateTemplateExerciseRequest{
+			ExerciseID: exerciseID,
+			Sets:       len(working),
+		}
+
+		var hasTargets bool
+		targets := make([]TemplateSetRequest, len(working))
+		for i, set := range working {
+			if set.Reps > 0 {
+				reps := set.Reps
+				targets[i].TargetReps = &reps
+				hasTargets = true
+			}
+			if set.Weight > 0 {
+				weight := set.Weight
+				targets[i].TargetWeight = &weight
+				hasTargets = true
+			}
+		}
+		if hasTargets {
+			exercise.SetTargets = targets
+		}
+
+		for _, set := range working {
+			if set.GroupID != nil && set.GroupType != nil {
+				exercise.Group = &ExerciseGroupRequest{
+					ID:     *set.GroupID,
+					Type:   *set.GroupType,
+					Rounds: len(working),
+				}
+				break
+			}
+		}
+
+		exercises = append(exercises, exercise)
+	}
+	return exercises
+}
+
+// respondWithNewTemplate loads a template just created for the user and writes
+// it in the user's preferred unit
+func respondWithNewTemplate(db *gorm.DB, c *gin.Context, templateID uint, userID interface{}, message string) {
+	if templateID == 0 {
+		return
+	}
+
+	template, err := loadUserTemplate(db, templateID, userID)
+	if err != nil {
+		appErr := utils.NewDatabaseError("Failed to load template", err)
+		utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+		return
+	}
+
+	preferredUnit, appErr := preferredWeightUnit(db, userID)
+	if appErr != nil {
+		utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+		return
+	}
+
+	utils.CreatedResponse(c, message, toTemplateResponse(template, preferredUnit))
+}
@@ -0,0 +1,395 @@
+package controllers
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/rachitnimje/trackle-web/models"
+	"github.com/rachitnimje/trackle-web/utils"
+)
+
+// UpdateTemplateVisibilityRequest shares or unshares a template. Sharing gives
+// it a link that stays the same until RegenerateLink is set; making it private
+// revokes the link.
+type UpdateTemplateVisibilityRequest struct {
+	Visibility     string `json:"visibility" binding:"required,oneof=private unlisted public"`
+	RegenerateLink bool   `json:"regenerate_link"`
+}
+
+// ImportTemplateRequest names the copy of a shared template. The name defaults
+// to the shared template's.
+type ImportTemplateRequest struct {
+	Name string `json:"name"`
+}
+
+type TemplateShareResponse struct {
+	TemplateID uint    `json:"template_id"`
+	Visibility string  `json:"visibility"`
+	ShareToken *string `json:"share_token"`
+}
+
+// PublicTemplateResponse is a template listed in the public catalog. It is
+// identified by its share token.
+type PublicTemplateResponse struct {
+	ShareToken  string `json:"share_token"`
+	CreatedAt   string `json:"created_at"`
+	UpdatedAt   string `json:"updated_at"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Author      string `json:"author"`
+	Exercises   int    `json:"exercises"`
+	Imports     int64  `json:"imports"`
+}
+
+// SharedTemplateResponse is a template viewed through its share link
+type SharedTemplateResponse struct {
+	TemplateResponse
+	Author  string `json:"author"`
+	Imports int64  `json:"imports"`
+}
+
+// Sort orders of the public catalog
+var publicTemplateOrders = map[string]string{
+	"popular": "(SELECT COUNT(*) FROM templates AS copies WHERE copies.source_template_id = templates.id AND copies.deleted_at IS NULL) DESC, created_at DESC",
+	"recent":  "created_at DESC",
+	"name":    "name ASC",
+}
+
+// UpdateTemplateVisibility sets who can see one of the user's templates.
+// Templates with custom exercises cannot be shared, since other users cannot
+// see those exercises.
+func UpdateTemplateVisibility(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
+		// extract the user_id from context
+		userID, exists := c.Get("user_id")
+		if !exists {
+			appErr := utils.NewAuthenticationError("User not authenticated", nil)
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
+		// parse the template ID with validation
+		templateID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil || templateID == 0 {
+			appErr := utils.NewInvalidInputError("Invalid template ID", err)
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
+		var req UpdateTemplateVisibilityRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			appErr := utils.NewValidationError("Invalid request data", err)
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
+		template, err := loadUserTemplate(db, uint(templateID), userID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				appErr := utils.NewNotFoundError("Template not found", nil)
+				utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			} else {
+				appErr := utils.NewDatabaseError("Failed to fetch template", err)
+				utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			}
+			return
+		}
+
+		template.Visibility = req.Visibility
+		if req.Visibility == models.VisibilityPrivate {
+			template.ShareToken = nil
+		} else {
+			for _, templateExercise := range template.Exercises {
+				if templateExercise.Exercise.UserID != nil {
+					appErr := utils.NewInvalidInputError("Templates with custom exercises cannot be shared", nil)
+					utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+					return
+				}
+			}
+
+			if template.ShareToken == nil || req.RegenerateLink {
+				token, err := utils.GenerateShareToken()
+				if err != nil {
+					appErr := utils.NewInternalError("Failed to generate share link", err)
+					utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+					return
+				}
+				template.ShareToken = &token
+			}
+		}
+
+		if err := db.Model(&template).Select("Visibility", "ShareToken").Updates(&template).Error; err != nil {
+			appErr := utils.NewDatabaseError("Failed to update template visibility", err)
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
+		utils.SuccessResponse(c, "Template visibility updated successfully", TemplateShareResponse{
+			TemplateID: template.ID,
+			Visibility: template.Visibility,
+			ShareToken: template.ShareToken,
+		})
+	}
+}
+
+// GetPublicTemplates lists the public template catalog. Templates can be
+// searched by name or description and sorted by popularity (how often they
+// were imported), recency or name.
+func GetPublicTemplates(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
+		// Parse pagination parameters
+		page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+		if err != nil || page < 1 {
+			page = 1
+		}
+
+		limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
+		if err != nil || limit < 1 || limit > 100 {
+			limit = 10
+		}
+
+		offset := (page - 1) * limit
+
+		order, ok := publicTemplateOrders[c.DefaultQuery("sort", "popular")]
+		if !ok {
+			appErr := utils.NewInvalidInputError("Invalid sort. Must be one of: popular, recent, name", nil)
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
+		query := db.Model(&models.Template{}).Where("visibility = ?", models.VisibilityPublic)
+		if search := c.Query("search"); search != "" {
+			query = query.Where("name ILIKE ? OR description ILIKE ?", "%"+search+"%", "%"+search+"%")
+		}
+
+		var total int64
+		if err := query.Count(&total).Error; err != nil {
+			appErr := utils.NewDatabaseError("Failed to count templates", err)
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
+		var templates []models.Template
+		if err := query.Preload("User").
+			Preload("Exercises").
+			Order(order).
+			Offset(offset).
+			Limit(limit).
+			Find(&templates).Error; err != nil {
+			appErr := utils.NewDatabaseError("Failed to fetch templates", err)
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
+		templateIDs := make([]uint, len(templates))
+		for i, template := range templates {
+			templateIDs[i] = template.ID
+		}
+		imports, err := templateImportCounts(db, templateIDs)
+		if err != nil {
+			appErr := utils.NewDatabaseError("Failed to count template imports", err)
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
+		response := make([]PublicTemplateResponse, 0, len(templates))
+		for _, template := range templates {
+			response = append(response, PublicTemplateResponse{
+				ShareToken:  *template.ShareToken,
+				CreatedAt:   template.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
+				UpdatedAt:   template.UpdatedAt.Format("2006-01-02T15:04:05Z07:00"),
+				Name:        template.Name,
+				Description: template.Description,
+				Author:      template.User.Username,
+				Exercises:   len(template.Exercises),
+				Imports:     imports[template.ID],
+			})
+		}
+
+		utils.PaginatedResponse(c, "Templates retrieved successfully", response, page, limit, total)
+	}
+}
+
+// GetSharedTemplate shows an unlisted or public template through its share link
+func GetSharedTemplate(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
+		// extract the user_id from context
+		userID, exists := c.Get("user_id")
+		if !exists {
+			appErr := utils.NewAuthenticationError("User not authenticated", nil)
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
+		template, appErr := loadSharedTemplate(db, c.Param("token"))
+		if appErr != nil {
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
+		imports, err := templateImportCounts(db, []uint{template.ID})
+		if err != nil {
+			appErr := utils.NewDatabaseError("Failed to count template imports", err)
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
+		preferredUnit, appErr := preferredWeightUnit(db, userID)
+		if appErr != nil {
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
+		utils.SuccessResponse(c, "Template retrieved successfully", SharedTemplateResponse{
+			TemplateResponse: toTemplateResponse(template, preferredUnit),
+			Author:           template.User.Username,
+			Imports:          imports[template.ID],
+		})
+	}
+}
+
+// ImportSharedTemplate copies an unlisted or public template into the user's
+// own templates. The copy is private and keeps the shared template as its
+// source for attribution.
+func ImportSharedTemplate(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
+		// extract the user_id from context
+		userID, exists := c.Get("user_id")
+		if !exists {
+			appErr := utils.NewAuthenticationError("User not authenticated", nil)
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
+		// the body is optional
+		var req ImportTemplateRequest
+		if c.Request.ContentLength != 0 {
+			if err := c.ShouldBindJSON(&req); err != nil {
+				appErr := utils.NewValidationError("Invalid request data", err)
+				utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+				return
+			}
+		}
+
+		shared, appErr := loadSharedTemplate(db, c.Param("token"))
+		if appErr != nil {
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
+		// custom exercises added after the template was shared are not visible to others
+		exerciseIDs := make([]uint, len(shared.Exercises))
+		for i, templateExercise := range shared.Exercises {
+			exerciseIDs[i] = templateExercise.ExerciseID
+		}
+		var visible int64
+		if err := visibleExercises(db.Model(&models.Exercise{}), userID).
+			Where("id IN ?", exerciseIDs).
+			Count(&visible).Error; err != nil {
+			appErr := utils.NewDatabaseError("Failed to verify exercises", err)
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+		if visible != int64(len(exerciseIDs)) {
+			appErr := utils.NewInvalidInputError("Template uses exercises that are not available to you", nil)
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
+		sourceID := shared.ID
+		copied := models.Template{
+			Name:             req.Name,
+			Description:      shared.Description,
+			UserID:           userID.(uint),
+			SourceTemplateID: &sourceID,
+			Exercises:        copyTemplateExercises(shared.Exercises),
+		}
+		if copied.Name == "" {
+			copied.Name = shared.Name
+		}
+
+		err := utils.TransactionManager(db, c, func(tx *gorm.DB) error {
+			// the exercises and set targets are created with the template
+			if err := tx.Create(&copied).Error; err != nil {
+				appErr = utils.NewDatabaseError("Failed to import template", err)
+				return appErr
+			}
+			return nil
+		})
+
+		if appErr != nil {
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+		if err != nil {
+			// the failed commit has been written
+			return
+		}
+
+		respondWithNewTemplate(db, c, copied.ID, userID, "Template imported successfully")
+	}
+}
+
+// loadSharedTemplate loads an unlisted or public template by its share token,
+// with its author
+func loadSharedTemplate(db *gorm.DB, token string) (models.Template, *utils.AppError) {
+	var template models.Template
+	if token == "" {
+		return template, utils.NewNotFoundError("Shared template not found", nil)
+	}
+
+	if err := preloadTemplateDetails(db).
+		Preload("User").
+		Where("share_token = ? AND visibility IN ?", token, []string{models.VisibilityUnlisted, models.VisibilityPublic}).
+		First(&template).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return template, utils.NewNotFoundError("Shared template not found", nil)
+		}
+		return template, utils.NewDatabaseError("Failed to fetch shared template", err)
+	}
+	return template, nil
+}
+
+// templateImportCounts counts the live copies imported from each template
+func templateImportCounts(db *gorm.DB, templateIDs []uint) (map[uint]int64, error) {
+	counts := make(map[uint]int64, len(templateIDs))
+	if len(templateIDs) == 0 {
+		return counts, nil
+	}
+
+	var rows []struct {
+		SourceTemplateID uint
+		Imports          int64
+	}
+	if err := db.Model(&models.Template{}).
+		Select("source_template_id, COUNT(*) AS imports").
+		Where("source_template_id IN ?", templateIDs).
+		Group("source_template_id").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		counts[row.SourceTemplateID] = row.Imports
+	}
+	return counts, nil
+}
+
+// hasCustomExercises reports whether any of the exercises is a user's custom exercise
+func hasCustomExercises(db *gorm.DB, exerciseIDs []uint) (bool, error) {
+	var custom int64
+	err := db.Model(&models.Exercise{}).
+		Where("id IN ? AND user_id IS NOT NULL", exerciseIDs).
+		Count(&custom).Error
+	return custom > 0, err
+}
@@ -0,0 +1,95 @@
+package controllers
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/rachitnimje/trackle-web/models"
+	"github.com/rachitnimje/trackle-web/utils"
+)
+
+// UpdateSettingsRequest only changes the settings that are present
+type UpdateSettingsRequest struct {
+	WeightUnit *string `json:"weight_unit" binding:"omitempty,oneof=kg lb"`
+	Timezone   *string `json:"timezone" binding:"omitempty,timezone"`
+}
+
+// UpdateUserSettings changes the user's preferences
+func UpdateUserSettings(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
+		// extract user_id from context
+		userID, exists := c.Get("user_id")
+		if !exists {
+			appErr := utils.NewAuthenticationError("User not authenticated", nil)
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
+		// bind and validate request
+		var req UpdateSettingsRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			appErr := utils.NewValidationError("Invalid request data", err)
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
+		var user models.User
+		if err := db.First(&user, userID).Error; err != nil {
+			appErr := utils.NewNotFoundError("User not found", err)
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
+		if req.WeightUnit != nil {
+			user.WeightUnit = *req.WeightUnit
+		}
+		if req.Timezone != nil {
+			user.Timezone = *req.Timezone
+			if user.Timezone == "" {
+				user.Timezone = utils.DefaultTimezone
+			}
+		}
+
+		if err := db.Model(&user).Select("WeightUnit", "Timezone").Updates(&user).Error; err != nil {
+			appErr := utils.NewDatabaseError("Failed to update settings", err)
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
+		utils.SuccessResponse(c, "Settings updated successfully", user)
+	}
+}
+
+// preferredWeightUnit returns the unit the user's weights are shown in
+func preferredWeightUnit(db *gorm.DB, userID interface{}) (string, *utils.AppError) {
+	var unit string
+	if err := db.Model(&models.User{}).Select("weight_unit").Where("id = ?", userID).Scan(&unit).Error; err != nil {
+		return "", utils.NewDatabaseError("Failed to load user settings", err)
+	}
+	if !utils.IsValidWeightUnit(unit) {
+		return utils.UnitKg, nil
+	}
+	return unit, nil
+}
+
+// preferredLocation returns the timezone the user's workouts are dated in
+func preferredLocation(db *gorm.DB, userID interface{}) (*time.Location, *utils.AppError) {
+	var timezone string
+	if err := db.Model(&models.User{}).Select("timezone").Where("id = ?", userID).Scan(&timezone).Error; err != nil {
+		return nil, utils.NewDatabaseError("Failed to load user settings", err)
+	}
+	return utils.LoadTimezone(timezone), nil
+}
+
+// requestWeightUnit returns the unit a request's weights are given in: the
+// unit named in the request, or the user's preferred unit
+func requestWeightUnit(requested, preferred string) string {
+	if requested != "" {
+		return requested
+	}
+	return preferred
+}
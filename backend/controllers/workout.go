@@ -2,58 +2,105 @@ package controllers
 
 import (
 	"errors"
+	"fmt"
+	"math"
 	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 
+	"github.com/rachitnimje/trackle-web/metrics"
 	"github.com/rachitnimje/trackle-web/models"
 	"github.com/rachitnimje/trackle-web/utils"
 )
 
+// CreateWorkoutRequest logs a completed workout. Entry weights are in Unit, or
+// in the user's preferred unit when it is omitted. PerformedAt backdates the
+// workout and defaults to StartedAt, or to now.
 type CreateWorkoutRequest struct {
-	Name       string                `json:"name" binding:"required"`
-	TemplateID uint                  `json:"template_id" binding:"required"`
-	Notes      string                `json:"notes"`
-	Entries    []WorkoutEntryRequest `json:"entries" binding:"required,min=1"`
+	Name        string                `json:"name" binding:"required"`
+	TemplateID  uint                  `json:"template_id" binding:"required"`
+	Notes       string                `json:"notes"`
+	PerformedAt *time.Time            `json:"performed_at"`
+	StartedAt   *time.Time            `json:"started_at"`
+	FinishedAt  *time.Time            `json:"finished_at"`
+	Unit        string                `json:"unit" binding:"omitempty,oneof=kg lb"`
+	Entries     []WorkoutEntryRequest `json:"entries" binding:"required,min=1"`
 }
 
+// WorkoutEntryRequest is one logged set. Which of reps, weight, duration and
+// distance are required depends on the exercise's tracking type. The set type
+// defaults to working; effort may be given as RPE or RIR, but not both. Sets
+// performed as a superset, circuit or giant set share a group ID and type.
 type WorkoutEntryRequest struct {
-	ExerciseID uint    `json:"exercise_id" binding:"required"`
-	SetNumber  int     `json:"set_number" binding:"required,min=1"`
-	Reps       int     `json:"reps" binding:"required,min=1"`
-	Weight     float64 `json:"weight" binding:"min=0"`
+	ExerciseID      uint     `json:"exercise_id" binding:"required"`
+	SetNumber       int      `json:"set_number" binding:"required,min=1"`
+	SetType         string   `json:"set_type" binding:"omitempty,oneof=warmup working drop failure amrap"`
+	Reps            int      `json:"reps" binding:"min=0"`
+	Weight          float64  `json:"weight" binding:"min=0"`
+	DurationSeconds *int     `json:"duration_seconds" binding:"omitempty,min=1"`
+	DistanceMeters  *float64 `json:"distance_meters" binding:"omitempty,gt=0"`
+	RPE             *float64 `json:"rpe" binding:"omitempty,min=1,max=10,excluded_with=RIR"`
+	RIR             *int     `json:"rir" binding:"omitempty,min=0,max=10"`
+	Note            string   `json:"note" binding:"max=500"`
+	GroupID         *int     `json:"group_id" binding:"required_with=GroupType,omitempty,min=1"`
+	GroupType       string   `json:"group_type" binding:"required_with=GroupID,omitempty,oneof=superset circuit giant_set"`
 }
 
 type UserWorkoutsResponse struct {
-	WorkoutID    uint      `json:"workout_id" binding:"required"`
-	WorkoutName  string    `json:"workout_name" binding:"required"`
-	TemplateID   uint      `json:"template_id" binding:"required"`
-	TemplateName string    `json:"template_name" binding:"required"`
-	LoggedAt     time.Time `json:"logged_at" binding:"required"`
-	Notes        string    `json:"notes" binding:"required"`
+	WorkoutID       uint       `json:"workout_id" binding:"required"`
+	WorkoutName     string     `json:"workout_name" binding:"required"`
+	TemplateID      uint       `json:"template_id" binding:"required"`
+	TemplateName    string     `json:"template_name" binding:"required"`
+	LoggedAt        time.Time  `json:"logged_at" binding:"required"`
+	PerformedAt     time.Time  `json:"performed_at" binding:"required"`
+	Notes           string     `json:"notes" binding:"required"`
+	StartedAt       *time.Time `json:"started_at"`
+	FinishedAt      *time.Time `json:"finished_at"`
+	DurationMinutes *int       `json:"duration_minutes"`
+	InProgress      bool       `json:"in_progress"`
 }
 
 type UserWorkoutResponse struct {
 	gorm.Model
-	TemplateID   uint                       `json:"template_id" binding:"required"`
-	TemplateName string                     `json:"template_name" binding:"required"`
-	WorkoutName  string                     `json:"workout_name" binding:"required"`
-	Notes        string                     `json:"notes" binding:"required"`
-	Entries      []UserWorkoutEntryResponse `json:"entries" binding:"required"`
+	TemplateID      uint                       `json:"template_id" binding:"required"`
+	TemplateName    string                     `json:"template_name" binding:"required"`
+	WorkoutName     string                     `json:"workout_name" binding:"required"`
+	Notes           string                     `json:"notes" binding:"required"`
+	PerformedAt     time.Time                  `json:"performed_at" binding:"required"`
+	StartedAt       *time.Time                 `json:"started_at"`
+	FinishedAt      *time.Time                 `json:"finished_at"`
+	DurationMinutes *int                       `json:"duration_minutes"`
+	InProgress      bool                       `json:"in_progress"`
+	WeightUnit      string                     `json:"weight_unit"`
+	Entries         []UserWorkoutEntryResponse `json:"entries" binding:"required"`
+	Groups          []ExerciseGroupResponse    `json:"groups"`
+	NewRecords      []PersonalRecordResponse   `json:"new_records,omitempty"`
 }
 
 type UserWorkoutEntryResponse struct {
-	ExerciseID   uint    `json:"exercise_id" binding:"required"`
-	ExerciseName string  `json:"exercise_name" binding:"required"`
-	SetNumber    int     `json:"set_number" binding:"required"`
-	Reps         int     `json:"reps" binding:"required"`
-	Weight       float64 `json:"weight" binding:"required"`
+	EntryID         uint     `json:"entry_id" binding:"required"`
+	ExerciseID      uint     `json:"exercise_id" binding:"required"`
+	ExerciseName    string   `json:"exercise_name" binding:"required"`
+	TrackingType    string   `json:"tracking_type" binding:"required"`
+	SetNumber       int      `json:"set_number" binding:"required"`
+	SetType         string   `json:"set_type" binding:"required"`
+	Reps            int      `json:"reps" binding:"required"`
+	Weight          float64  `json:"weight" binding:"required"`
+	DurationSeconds *int     `json:"duration_seconds"`
+	DistanceMeters  *float64 `json:"distance_meters"`
+	RPE             *float64 `json:"rpe"`
+	RIR             *int     `json:"rir"`
+	Note            string   `json:"note"`
+	GroupID         *int     `json:"group_id"`
+	GroupType       *string  `json:"group_type"`
 }
 
 func CreateUserWorkout(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
 		// extract user_id from context
 		userID, exists := c.Get("user_id")
 		if !exists {
@@ -70,6 +117,12 @@ func CreateUserWorkout(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
+		// check the session times are consistent
+		if appErr := validateWorkoutTimes(req.PerformedAt, req.StartedAt, req.FinishedAt); appErr != nil {
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
 		// check if the template belongs to the user
 		var template models.Template
 		if err := db.Where("id = ? and user_id = ?", req.TemplateID, userID).
@@ -95,75 +148,115 @@ func CreateUserWorkout(db *gorm.DB) gin.HandlerFunc {
 			exerciseIDs = append(exerciseIDs, id)
 		}
 
-		// Verify all exercises exist
-		var exerciseCount int64
-		if err := db.Model(&models.Exercise{}).
-			Where("id IN ?", exerciseIDs).
-			Count(&exerciseCount).Error; err != nil {
-			appErr := utils.NewDatabaseError("Failed to verify exercises", err)
+		// Verify all exercises are visible to the user and each set fits its exercise
+		if appErr := validateWorkoutEntries(db, userID, exerciseIDs, req.Entries); appErr != nil {
 			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
 			return
 		}
 
-		if int(exerciseCount) != len(exerciseIDs) {
-			appErr := utils.NewInvalidInputError("One or more exercise IDs are invalid", nil)
+		// weights are stored in kg and returned in the user's preferred unit
+		preferredUnit, appErr := preferredWeightUnit(db, userID)
+		if appErr != nil {
 			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
 			return
 		}
+		inputUnit := requestWeightUnit(req.Unit, preferredUnit)
 
 		// Use our transaction manager for better error handling
-		utils.TransactionManager(db, c, func(tx *gorm.DB) error {
+		var response WorkoutSaveResponse
+		err := utils.TransactionManager(db, c, func(tx *gorm.DB) error {
 			// save the workout to db
 			workout := models.Workout{
-				Name:       req.Name,
-				UserID:     userID.(uint),
-				TemplateID: req.TemplateID,
-				Notes:      req.Notes,
+				Name:            req.Name,
+				UserID:          userID.(uint),
+				TemplateID:      req.TemplateID,
+				Notes:           req.Notes,
+				PerformedAt:     workoutPerformedAt(req.PerformedAt, req.StartedAt),
+				StartedAt:       req.StartedAt,
+				FinishedAt:      req.FinishedAt,
+				DurationMinutes: workoutDuration(req.StartedAt, req.FinishedAt),
 			}
 
 			if err := tx.Create(&workout).Error; err != nil {
-				return utils.NewDatabaseError("Failed to create workout", err)
+				appErr = utils.NewDatabaseError("Failed to create workout", err)
+				return appErr
 			}
 
 			// save the workout entries to db
 			var workoutEntries []models.WorkoutEntry
 			for _, r := range req.Entries {
 				workoutEntries = append(workoutEntries, models.WorkoutEntry{
-					WorkoutID:  workout.ID,
-					ExerciseID: r.ExerciseID,
-					SetNumber:  r.SetNumber,
-					Reps:       r.Reps,
-					Weight:     r.Weight,
+					WorkoutID:       workout.ID,
+					ExerciseID:      r.ExerciseID,
+					SetNumber:       r.SetNumber,
+					SetType:         entrySetType(r.SetType),
+					Reps:            r.Reps,
+					Weight:          utils.ToKg(r.Weight, inputUnit),
+					DurationSeconds: r.DurationSeconds,
+					DistanceMeters:  r.DistanceMeters,
+					RPE:             r.RPE,
+					RIR:             r.RIR,
+					Note:            r.Note,
+					GroupID:         r.GroupID,
+					GroupType:       entryGroupType(r.GroupID, r.GroupType),
 				})
 			}
 
 			if err := tx.Create(&workoutEntries).Error; err != nil {
-				return utils.NewDatabaseError("Failed to create workout entries", err)
+				appErr = utils.NewDatabaseError("Failed to create workout entries", err)
+				return appErr
+			}
+
+			// Detect personal records set by the new entries
+			if err := recomputePersonalRecords(tx, workout.UserID, exerciseIDs); err != nil {
+				if !errors.As(err, &appErr) {
+					appErr = utils.NewDatabaseError("Failed to update personal records", err)
+				}
+				return appErr
 			}
 
 			// Load the created workout with entries for response
 			var createdWorkout models.Workout
 			if err := tx.Preload("Entries.Exercise").First(&createdWorkout, workout.ID).Error; err != nil {
-				return utils.NewDatabaseError("Failed to load created workout", err)
+				appErr = utils.NewDatabaseError("Failed to load created workout", err)
+				return appErr
+			}
+
+			newRecords, err := workoutRecords(tx, workout.ID, preferredUnit)
+			if err != nil {
+				appErr = utils.NewDatabaseError("Failed to load personal records", err)
+				return appErr
 			}
 
 			// Prepare response data outside the transaction
-			c.Set("created_workout", createdWorkout)
+			displayWorkoutWeights(&createdWorkout, preferredUnit)
+			response = WorkoutSaveResponse{
+				Workout:    createdWorkout,
+				WeightUnit: preferredUnit,
+				NewRecords: newRecords,
+			}
 			return nil
 		})
 
-		// Get workout from context if available
-		createdWorkout, exists := c.Get("created_workout")
-		if exists {
-			utils.CreatedResponse(c, "Workout created successfully", createdWorkout)
-		} else {
-			utils.CreatedResponse(c, "Workout created successfully", nil)
+		if appErr != nil {
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
 		}
+		if err != nil {
+			// the failed commit has been written
+			return
+		}
+
+		// count the workout only once it is committed
+		metrics.WorkoutsLogged.WithLabelValues(metrics.WorkoutSourceManual).Inc()
+		utils.CreatedResponse(c, "Workout created successfully", response)
 	}
 }
 
 func GetAllUserWorkouts(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
 		// extract user_id from context
 		userID, exists := c.Get("user_id")
 		if !exists {
@@ -218,7 +311,7 @@ func GetAllUserWorkouts(db *gorm.DB) gin.HandlerFunc {
 		// Fetch workouts with pagination and filters
 		var workouts []models.Workout
 		if err := query.Preload("Template").
-			Order("created_at DESC").
+			Order("performed_at DESC, id DESC").
 			Offset(offset).
 			Limit(limit).
 			Find(&workouts).Error; err != nil {
@@ -231,12 +324,17 @@ func GetAllUserWorkouts(db *gorm.DB) gin.HandlerFunc {
 		var userWorkoutsResponse []UserWorkoutsResponse
 		for _, workout := range workouts {
 			userWorkoutsResponse = append(userWorkoutsResponse, UserWorkoutsResponse{
-				WorkoutID:    workout.ID,
-				WorkoutName:  workout.Name,
-				TemplateID:   workout.TemplateID,
-				TemplateName: workout.Template.Name, // Use preloaded template name
-				LoggedAt:     workout.CreatedAt,
-				Notes:        workout.Notes,
+				WorkoutID:       workout.ID,
+				WorkoutName:     workout.Name,
+				TemplateID:      workout.TemplateID,
+				TemplateName:    workout.Template.Name, // Use preloaded template name
+				LoggedAt:        workout.CreatedAt,
+				PerformedAt:     workout.PerformedAt,
+				Notes:           workout.Notes,
+				StartedAt:       workout.StartedAt,
+				FinishedAt:      workout.FinishedAt,
+				DurationMinutes: workout.DurationMinutes,
+				InProgress:      workout.IsActive(),
 			})
 		}
 
@@ -246,6 +344,8 @@ func GetAllUserWorkouts(db *gorm.DB) gin.HandlerFunc {
 
 func GetUserWorkout(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
 		// extract user_id from context
 		userID, exists := c.Get("user_id")
 		if !exists {
@@ -294,41 +394,34 @@ func GetUserWorkout(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
-		// Map the entries to the response structure
-		var workoutEntriesResponse []UserWorkoutEntryResponse
-		for _, entry := range workoutEntries {
-			workoutEntriesResponse = append(workoutEntriesResponse, UserWorkoutEntryResponse{
-				ExerciseID:   entry.ExerciseID,
-				ExerciseName: entry.Exercise.Name, // Access the preloaded Exercise data
-				SetNumber:    entry.SetNumber,
-				Reps:         entry.Reps,
-				Weight:       entry.Weight,
-			})
+		preferredUnit, appErr := preferredWeightUnit(db, userID)
+		if appErr != nil {
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
 		}
 
 		// Build the final response
-		response := UserWorkoutResponse{
-			Model:        workout.Model,
-			TemplateID:   workout.TemplateID,
-			TemplateName: workout.Template.Name, // Access the preloaded Template data
-			WorkoutName:  workout.Name,
-			Notes:        workout.Notes,
-			Entries:      workoutEntriesResponse,
-		}
+		response := toUserWorkoutResponse(workout, workoutEntries, preferredUnit)
 
 		utils.SuccessResponse(c, "Workout retrieved successfully", response)
 	}
 }
 
 type UpdateWorkoutRequest struct {
-	Name       string                `json:"name" binding:"required"`
-	TemplateID uint                  `json:"template_id" binding:"required"`
-	Notes      string                `json:"notes"`
-	Entries    []WorkoutEntryRequest `json:"entries" binding:"required,min=1"`
+	Name        string                `json:"name" binding:"required"`
+	TemplateID  uint                  `json:"template_id" binding:"required"`
+	Notes       string                `json:"notes"`
+	PerformedAt *time.Time            `json:"performed_at"`
+	StartedAt   *time.Time            `json:"started_at"`
+	FinishedAt  *time.Time            `json:"finished_at"`
+	Unit        string                `json:"unit" binding:"omitempty,oneof=kg lb"`
+	Entries     []WorkoutEntryRequest `json:"entries" binding:"required,min=1"`
 }
 
 func UpdateUserWorkout(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
 		// extract user_id from context
 		userID, exists := c.Get("user_id")
 		if !exists {
@@ -373,6 +466,12 @@ func UpdateUserWorkout(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
+		// check the session times are consistent
+		if appErr := validateWorkoutTimes(req.PerformedAt, req.StartedAt, req.FinishedAt); appErr != nil {
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+
 		// check if the template belongs to the user
 		var template models.Template
 		if err := db.Where("id = ? and user_id = ?", req.TemplateID, userID).
@@ -398,77 +497,129 @@ func UpdateUserWorkout(db *gorm.DB) gin.HandlerFunc {
 			exerciseIDs = append(exerciseIDs, id)
 		}
 
-		// Verify all exercises exist
-		var exerciseCount int64
-		if err := db.Model(&models.Exercise{}).
-			Where("id IN ?", exerciseIDs).
-			Count(&exerciseCount).Error; err != nil {
-			appErr := utils.NewDatabaseError("Failed to verify exercises", err)
+		// Verify all exercises are visible to the user and each set fits its exercise
+		if appErr := validateWorkoutEntries(db, userID, exerciseIDs, req.Entries); appErr != nil {
 			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
 			return
 		}
 
-		if int(exerciseCount) != len(exerciseIDs) {
-			appErr := utils.NewInvalidInputError("One or more exercise IDs are invalid", nil)
+		// weights are stored in kg and returned in the user's preferred unit
+		preferredUnit, appErr := preferredWeightUnit(db, userID)
+		if appErr != nil {
 			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
 			return
 		}
+		inputUnit := requestWeightUnit(req.Unit, preferredUnit)
 
 		// Use our transaction manager for better error handling
-		utils.TransactionManager(db, c, func(tx *gorm.DB) error {
-			// Update the workout
+		var response WorkoutSaveResponse
+		err = utils.TransactionManager(db, c, func(tx *gorm.DB) error {
+			// Update the workout, keeping the recorded session times unless new ones are given
 			workout.Name = req.Name
 			workout.TemplateID = req.TemplateID
 			workout.Notes = req.Notes
+			if req.PerformedAt != nil {
+				workout.PerformedAt = *req.PerformedAt
+			}
+			if req.StartedAt != nil {
+				workout.StartedAt = req.StartedAt
+			}
+			if req.FinishedAt != nil {
+				workout.FinishedAt = req.FinishedAt
+			}
+			workout.DurationMinutes = workoutDuration(workout.StartedAt, workout.FinishedAt)
 
 			if err := tx.Save(&workout).Error; err != nil {
-				return utils.NewDatabaseError("Failed to update workout", err)
+				appErr = utils.NewDatabaseError("Failed to update workout", err)
+				return appErr
+			}
+
+			// Remember the exercises being replaced so their records are recomputed too
+			previousExerciseIDs, err := workoutExerciseIDs(tx, workout.ID)
+			if err != nil {
+				appErr = utils.NewDatabaseError("Failed to load existing workout entries", err)
+				return appErr
 			}
 
 			// Delete existing workout entries
 			if err := tx.Where("workout_id = ?", workout.ID).Delete(&models.WorkoutEntry{}).Error; err != nil {
-				return utils.NewDatabaseError("Failed to delete existing workout entries", err)
+				appErr = utils.NewDatabaseError("Failed to delete existing workout entries", err)
+				return appErr
 			}
 
 			// Create new workout entries
 			var workoutEntries []models.WorkoutEntry
 			for _, r := range req.Entries {
 				workoutEntries = append(workoutEntries, models.WorkoutEntry{
-					WorkoutID:  workout.ID,
-					ExerciseID: r.ExerciseID,
-					SetNumber:  r.SetNumber,
-					Reps:       r.Reps,
-					Weight:     r.Weight,
+					WorkoutID:       workout.ID,
+					ExerciseID:      r.ExerciseID,
+					SetNumber:       r.SetNumber,
+					SetType:         entrySetType(r.SetType),
+					Reps:            r.Reps,
+					Weight:          utils.ToKg(r.Weight, inputUnit),
+					DurationSeconds: r.DurationSeconds,
+					DistanceMeters:  r.DistanceMeters,
+					RPE:             r.RPE,
+					RIR:             r.RIR,
+					Note:            r.Note,
+					GroupID:         r.GroupID,
+					GroupType:       entryGroupType(r.GroupID, r.GroupType),
 				})
 			}
 
 			if err := tx.Create(&workoutEntries).Error; err != nil {
-				return utils.NewDatabaseError("Failed to create workout entries", err)
+				appErr = utils.NewDatabaseError("Failed to create workout entries", err)
+				return appErr
+			}
+
+			// Recompute personal records for every exercise the edit touched
+			if err := recomputePersonalRecords(tx, workout.UserID, append(previousExerciseIDs, exerciseIDs...)); err != nil {
+				if !errors.As(err, &appErr) {
+					appErr = utils.NewDatabaseError("Failed to update personal records", err)
+				}
+				return appErr
 			}
 
 			// Load the updated workout with entries for response
 			var updatedWorkout models.Workout
 			if err := tx.Preload("Entries.Exercise").First(&updatedWorkout, workout.ID).Error; err != nil {
-				return utils.NewDatabaseError("Failed to load updated workout", err)
+				appErr = utils.NewDatabaseError("Failed to load updated workout", err)
+				return appErr
+			}
+
+			newRecords, err := workoutRecords(tx, workout.ID, preferredUnit)
+			if err != nil {
+				appErr = utils.NewDatabaseError("Failed to load personal records", err)
+				return appErr
 			}
 
 			// Prepare response data outside the transaction
-			c.Set("updated_workout", updatedWorkout)
+			displayWorkoutWeights(&updatedWorkout, preferredUnit)
+			response = WorkoutSaveResponse{
+				Workout:    updatedWorkout,
+				WeightUnit: preferredUnit,
+				NewRecords: newRecords,
+			}
 			return nil
 		})
 
-		// Get workout from context if available
-		updatedWorkout, exists := c.Get("updated_workout")
-		if exists {
-			utils.SuccessResponse(c, "Workout updated successfully", updatedWorkout)
-		} else {
-			utils.SuccessResponse(c, "Workout updated successfully", nil)
+		if appErr != nil {
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+		if err != nil {
+			// the failed commit has been written
+			return
 		}
+
+		utils.SuccessResponse(c, "Workout updated successfully", response)
 	}
 }
 
 func DeleteUserWorkout(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
 		// retrieve the user id from context
 		userID, exists := c.Get("user_id")
 		if !exists {
@@ -505,20 +656,220 @@ func DeleteUserWorkout(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
-		utils.TransactionManager(db, c, func(tx *gorm.DB) error {
+		var appErr *utils.AppError
+		err = utils.TransactionManager(db, c, func(tx *gorm.DB) error {
+			exerciseIDs, err := workoutExerciseIDs(tx, workout.ID)
+			if err != nil {
+				appErr = utils.NewDatabaseError("Failed to load workout entries", err)
+				return appErr
+			}
+
 			// Delete workout entries first
 			if err := tx.Where("workout_id = ?", workoutID).Delete(&models.WorkoutEntry{}).Error; err != nil {
-				return utils.NewDatabaseError("Failed to delete workout entries", err)
+				appErr = utils.NewDatabaseError("Failed to delete workout entries", err)
+				return appErr
 			}
 
 			// Delete workout
 			if err := tx.Delete(&workout).Error; err != nil {
-				return utils.NewDatabaseError("Failed to delete workout", err)
+				appErr = utils.NewDatabaseError("Failed to delete workout", err)
+				return appErr
+			}
+
+			// Records set in this workout no longer count
+			if err := recomputePersonalRecords(tx, workout.UserID, exerciseIDs); err != nil {
+				if !errors.As(err, &appErr) {
+					appErr = utils.NewDatabaseError("Failed to update personal records", err)
+				}
+				return appErr
 			}
 
 			return nil
 		})
 
+		if appErr != nil {
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			return
+		}
+		if err != nil {
+			// the failed commit has been written
+			return
+		}
+
 		utils.SuccessResponse(c, "Workout deleted successfully", nil)
 	}
 }
+
+// maxClockSkew is how far in the future a workout's times may be, to allow for
+// clients whose clocks run ahead
+const maxClockSkew = 5 * time.Minute
+
+// validateWorkoutTimes checks that a workout does not finish before it starts
+// and was not performed in the future
+func validateWorkoutTimes(performedAt, startedAt, finishedAt *time.Time) *utils.AppError {
+	if startedAt != nil && finishedAt != nil && finishedAt.Before(*startedAt) {
+		return utils.NewInvalidInputError("finished_at must not be before started_at", nil)
+	}
+	if performedAt != nil && performedAt.After(time.Now().Add(maxClockSkew)) {
+		return utils.NewInvalidInputError("performed_at must not be in the future", nil)
+	}
+	return nil
+}
+
+// workoutPerformedAt returns when a new workout was performed: the time given,
+// else when it was started, else now
+func workoutPerformedAt(performedAt, startedAt *time.Time) time.Time {
+	if performedAt != nil {
+		return *performedAt
+	}
+	if startedAt != nil {
+		return *startedAt
+	}
+	return time.Now()
+}
+
+// validateWorkoutEntries checks that the exercises exist and are visible to the
+// user, that every entry records what its exercise's tracking type needs and
+// that grouped entries agree on their group
+func validateWorkoutEntries(db *gorm.DB, userID interface{}, exerciseIDs []uint, entries []WorkoutEntryRequest) *utils.AppError {
+	var exercises []models.Exercise
+	if err := visibleExercises(db.Model(&models.Exercise{}), userID).
+		Where("id IN ?", exerciseIDs).
+		Find(&exercises).Error; err != nil {
+		return utils.NewDatabaseError("Failed to verify exercises", err)
+	}
+
+	if len(exercises) != len(exerciseIDs) {
+		return utils.NewInvalidInputError("One or more exercise IDs are invalid", nil)
+	}
+
+	exercisesByID := make(map[uint]models.Exercise, len(exercises))
+	for _, exercise := range exercises {
+		exercisesByID[exercise.ID] = exercise
+	}
+
+	for _, entry := range entries {
+		if appErr := validateEntryMetrics(exercisesByID[entry.ExerciseID], entry.Reps, entry.Weight, entry.DurationSeconds, entry.DistanceMeters); appErr != nil {
+			return appErr
+		}
+	}
+	return validateEntryGroups(entries)
+}
+
+// validateEntryMetrics checks a set records what the exercise's tracking type
+// needs and nothing it does not track
+func validateEntryMetrics(exercise models.Exercise, reps int, weight float64, durationSeconds *int, distanceMeters *float64) *utils.AppError {
+	if problem := entryMetricsProblem(exercise, reps, weight, durationSeconds, distanceMeters); problem != "" {
+		return utils.NewInvalidInputError(fmt.Sprintf("%s %s", exercise.Name, problem), nil)
+	}
+	return nil
+}
+
+// entryMetricsProblem describes what is wrong with a set's metrics for the
+// exercise's tracking type, or returns "" when they fit
+func entryMetricsProblem(exercise models.Exercise, reps int, weight float64, durationSeconds *int, distanceMeters *float64) string {
+	var problem string
+	switch exercise.TrackingType {
+	case models.TrackingRepsOnly:
+		switch {
+		case reps < 1:
+			problem = "needs reps"
+		case weight > 0:
+			problem = "does not track weight"
+		case durationSeconds != nil || distanceMeters != nil:
+			problem = "does not track duration or distance"
+		}
+	case models.TrackingDuration:
+		switch {
+		case durationSeconds == nil:
+			problem = "needs a duration"
+		case reps > 0:
+			problem = "does not track reps"
+		case distanceMeters != nil:
+			problem = "does not track distance"
+		}
+	case models.TrackingDistanceDuration:
+		switch {
+		case distanceMeters == nil || durationSeconds == nil:
+			problem = "needs a distance and a duration"
+		case reps > 0 || weight > 0:
+			problem = "does not track reps or weight"
+		}
+	default:
+		switch {
+		case reps < 1:
+			problem = "needs reps"
+		case durationSeconds != nil || distanceMeters != nil:
+			problem = "does not track duration or distance"
+		}
+	}
+	return problem
+}
+
+// entrySetType returns the set type of an entry, defaulting to a working set
+func entrySetType(setType string) string {
+	if setType == "" {
+		return models.SetTypeWorking
+	}
+	return setType
+}
+
+// workoutDuration returns the session length in whole minutes, or nil if the
+// workout has not both started and finished
+func workoutDuration(startedAt, finishedAt *time.Time) *int {
+	if startedAt == nil || finishedAt == nil {
+		return nil
+	}
+	minutes := int(math.Round(finishedAt.Sub(*startedAt).Minutes()))
+	return &minutes
+}
+
+// displayWorkoutWeights converts the weights of a workout's preloaded entries
+// from kg to unit
+func displayWorkoutWeights(workout *models.Workout, unit string) {
+	for i := range workout.Entries {
+		workout.Entries[i].Weight = utils.DisplayWeight(workout.Entries[i].Weight, unit)
+	}
+}
+
+// toUserWorkoutResponse maps a workout with its preloaded template and entries
+// to its DTO, with weights in unit
+func toUserWorkoutResponse(workout models.Workout, entries []models.WorkoutEntry, unit string) UserWorkoutResponse {
+	// Map the entries to the response structure
+	workoutEntriesResponse := make([]UserWorkoutEntryResponse, 0, len(entries))
+	for _, entry := range entries {
+		workoutEntriesResponse = append(workoutEntriesResponse, UserWorkoutEntryResponse{
+			EntryID:         entry.ID,
+			ExerciseID:      entry.ExerciseID,
+			ExerciseName:    entry.Exercise.Name, // Access the preloaded Exercise data
+			TrackingType:    entry.Exercise.TrackingType,
+			SetNumber:       entry.SetNumber,
+			SetType:         entry.SetType,
+			Reps:            entry.Reps,
+			Weight:          utils.DisplayWeight(entry.Weight, unit),
+			DurationSeconds: entry.DurationSeconds,
+			DistanceMeters:  entry.DistanceMeters,
+			RPE:             entry.RPE,
+			RIR:             entry.RIR,
+			Note:            entry.Note,
+			GroupID:         entry.GroupID,
+			GroupType:       entry.GroupType,
+		})
+	}
+
+	return UserWorkoutResponse{
+		Model:           workout.Model,
+		TemplateID:      workout.TemplateID,
+		TemplateName:    workout.Template.Name, // Access the preloaded Template data
+		WorkoutName:     workout.Name,
+		Notes:           workout.Notes,
+		PerformedAt:     workout.PerformedAt,
+		StartedAt:       workout.StartedAt,
+		FinishedAt:      workout.FinishedAt,
+		DurationMinutes: workout.DurationMinutes,
+		InProgress:      workout.IsActive(),
+		WeightUnit:      unit,
+		Entries:         workoutEntriesResponse,
+		Groups:          workoutGroups(entries),
+	}
+}
@@ -0,0 +1,118 @@
+package imports
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/rachitnimje/trackle-web/models"
+)
+
+// hevyTimeLayouts are the timestamp formats Hevy has used in its exports
+var hevyTimeLayouts = []string{
+	"2 Jan 2006, 15:04",
+	"02 Jan 2006, 15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	time.RFC3339,
+}
+
+// hevyParser reads the CSV export of the Hevy app: one row per set, with the
+// workout repeated on every row. The weight column is weight_kg or weight_lbs
+// and the distance column distance_km or distance_miles.
+type hevyParser struct {
+	loc *time.Location
+}
+
+func (p *hevyParser) check(columns map[string]int) error {
+	if err := requireColumns(columns, "title", "start_time", "exercise_title", "reps"); err != nil {
+		return err
+	}
+	_, kg := columns["weight_kg"]
+	_, lbs := columns["weight_lbs"]
+	if !kg && !lbs {
+		return fmt.Errorf("missing columns: weight_kg or weight_lbs")
+	}
+	return nil
+}
+
+func (p *hevyParser) parse(r row) (string, Workout, *Set, string) {
+	start := r.get("start_time")
+	name := r.get("title")
+
+	startedAt, err := parseTime(start, p.loc, hevyTimeLayouts...)
+	if err != nil {
+		return "", Workout{}, nil, err.Error()
+	}
+
+	workout := Workout{
+		Name:      name,
+		StartedAt: startedAt,
+		Notes:     r.get("description"),
+	}
+	if end := r.get("end_time"); end != "" {
+		if finishedAt, err := parseTime(end, p.loc, hevyTimeLayouts...); err == nil && !finishedAt.Before(startedAt) {
+			workout.FinishedAt = &finishedAt
+		}
+	}
+	key := start + "|" + name
+
+	exercise := r.get("exercise_title")
+	if exercise == "" {
+		return key, workout, nil, "skipped a set without an exercise name"
+	}
+	reps, err := parseNumber(r.get("reps"))
+	if err != nil {
+		return key, workout, nil, fmt.Sprintf("invalid reps %q", r.get("reps"))
+	}
+
+	durationSeconds, err := parseSeconds(r.get("duration_seconds"))
+	if err != nil {
+		return key, workout, nil, err.Error()
+	}
+	distanceColumn, metersPerUnit := "distance_km", float64(metersPerKilometre)
+	if r.has("distance_miles") {
+		distanceColumn, metersPerUnit = "distance_miles", metersPerMile
+	}
+	distanceMeters, err := parseDistance(r.get(distanceColumn), metersPerUnit)
+	if err != nil {
+		return key, workout, nil, err.Error()
+	}
+
+	if reps <= 0 && durationSeconds == nil && distanceMeters == nil {
+		return key, workout, nil, fmt.Sprintf("skipped a set of %q without reps, time or distance", exercise)
+	}
+
+	weightColumn := "weight_kg"
+	if r.has("weight_lbs") {
+		weightColumn = "weight_lbs"
+	}
+	weight, err := parseNumber(r.get(weightColumn))
+	if err != nil || weight < 0 {
+		return key, workout, nil, fmt.Sprintf("invalid weight %q", r.get(weightColumn))
+	}
+
+	return key, workout, &Set{
+		ExerciseName:    exercise,
+		SetType:         hevySetType(r.get("set_type")),
+		Reps:            int(math.Round(max(reps, 0))),
+		Weight:          weight,
+		DurationSeconds: durationSeconds,
+		DistanceMeters:  distanceMeters,
+		RPE:             parseRPE(r.get("rpe")),
+	}, ""
+}
+
+// hevySetType maps Hevy's set types to Trackle's
+func hevySetType(setType string) string {
+	switch setType {
+	case "warmup":
+		return models.SetTypeWarmup
+	case "dropset":
+		return models.SetTypeDrop
+	case "failure":
+		return models.SetTypeFailure
+	default:
+		return models.SetTypeWorking
+	}
+}
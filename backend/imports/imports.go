@@ -0,0 +1,287 @@
+// Package imports parses workout history exported by other tracking apps into
+// workouts that can be saved as Trackle workouts.
+package imports
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Supported export sources
+const (
+	SourceStrong = "strong"
+	SourceHevy   = "hevy"
+)
+
+// Workout is one session read from an export
+type Workout struct {
+	Name       string
+	StartedAt  time.Time
+	FinishedAt *time.Time
+	Notes      string
+	Sets       []Set
+}
+
+// Set is one logged set of a workout. The weight is in the unit of the Result,
+// the distance in meters and the set type is one of the models.SetType values.
+type Set struct {
+	Line            int
+	ExerciseName    string
+	SetType         string
+	Reps            int
+	Weight          float64
+	DurationSeconds *int
+	DistanceMeters  *float64
+	RPE             *float64
+	Note            string
+}
+
+// Meters in the distance units used by exports
+const (
+	metersPerKilometre = 1000
+	metersPerMile      = 1609.344
+)
+
+// Warning describes a row that was skipped or read with assumptions
+type Warning struct {
+	Line    int    `json:"line"`
+	Message string `json:"message"`
+}
+
+// Result is a parsed export: its workouts in chronological order, the unit of
+// their weights and the warnings raised while reading it
+type Result struct {
+	Source   string
+	Unit     string
+	Workouts []Workout
+	Warnings []Warning
+}
+
+// Parse reads a CSV export from source. When source is empty it is detected
+// from the header. unit is the weight unit of exports that do not name one;
+// dates without a time zone are read in loc.
+func Parse(source string, r io.Reader, unit string, loc *time.Location) (*Result, error) {
+	reader, err := newCSVReader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the CSV header: %w", err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+
+	if source == "" {
+		source = detect(columns)
+	}
+
+	var p parser
+	switch source {
+	case SourceStrong:
+		p = &strongParser{loc: loc}
+	case SourceHevy:
+		p = &hevyParser{loc: loc}
+		// Hevy names the unit in the weight column
+		if _, ok := columns["weight_lbs"]; ok {
+			unit = "lb"
+		} else {
+			unit = "kg"
+		}
+	default:
+		return nil, fmt.Errorf("unrecognised export format")
+	}
+	if err := p.check(columns); err != nil {
+		return nil, err
+	}
+
+	result := &Result{Source: source, Unit: unit}
+	byKey := make(map[string]*Workout)
+	var order []string
+
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := reader.FieldPos(0)
+
+		row := row{record: record, columns: columns}
+		key, workout, set, warning := p.parse(row)
+		if warning != "" {
+			result.Warnings = append(result.Warnings, Warning{Line: line, Message: warning})
+		}
+		if key == "" {
+			continue
+		}
+
+		existing, ok := byKey[key]
+		if !ok {
+			existing = &workout
+			byKey[key] = existing
+			order = append(order, key)
+		}
+		if set != nil {
+			set.Line = line
+			existing.Sets = append(existing.Sets, *set)
+		}
+	}
+
+	for _, key := range order {
+		workout := byKey[key]
+		if len(workout.Sets) == 0 {
+			result.Warnings = append(result.Warnings, Warning{
+				Message: fmt.Sprintf("workout %q on %s has no importable sets", workout.Name, workout.StartedAt.Format("2006-01-02")),
+			})
+			continue
+		}
+		result.Workouts = append(result.Workouts, *workout)
+	}
+
+	sort.SliceStable(result.Workouts, func(i, j int) bool {
+		return result.Workouts[i].StartedAt.Before(result.Workouts[j].StartedAt)
+	})
+	return result, nil
+}
+
+// parser reads the rows of one export format
+type parser interface {
+	// check verifies the header has the columns the format needs
+	check(columns map[string]int) error
+	// parse reads a row into the key of its workout, the workout's fields and
+	// its set. A row with no key is skipped; a row with a key but no set only
+	// describes its workout.
+	parse(row row) (key string, workout Workout, set *Set, warning string)
+}
+
+// newCSVReader reads comma or semicolon separated files, as exported in
+// locales that use a decimal comma
+func newCSVReader(r io.Reader) (*csv.Reader, error) {
+	buffered := bufio.NewReader(r)
+	firstLine, err := buffered.Peek(4096)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return nil, err
+	}
+	if i := bytes.IndexByte(firstLine, '\n'); i >= 0 {
+		firstLine = firstLine[:i]
+	}
+
+	reader := csv.NewReader(buffered)
+	if bytes.Count(firstLine, []byte(";")) > bytes.Count(firstLine, []byte(",")) {
+		reader.Comma = ';'
+	}
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	return reader, nil
+}
+
+// detect guesses the export source from the header columns
+func detect(columns map[string]int) string {
+	if _, ok := columns["exercise_title"]; ok {
+		return SourceHevy
+	}
+	if _, ok := columns["exercise name"]; ok {
+		return SourceStrong
+	}
+	return ""
+}
+
+// row gives access to the fields of a record by column name
+type row struct {
+	record  []string
+	columns map[string]int
+}
+
+func (r row) get(column string) string {
+	i, ok := r.columns[column]
+	if !ok || i >= len(r.record) {
+		return ""
+	}
+	return strings.TrimSpace(r.record[i])
+}
+
+// has reports whether the export has the column
+func (r row) has(column string) bool {
+	_, ok := r.columns[column]
+	return ok
+}
+
+func requireColumns(columns map[string]int, names ...string) error {
+	var missing []string
+	for _, name := range names {
+		if _, ok := columns[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("missing columns: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// parseNumber reads a number written with a decimal point or comma
+func parseNumber(value string) (float64, error) {
+	if value == "" {
+		return 0, nil
+	}
+	return strconv.ParseFloat(strings.ReplaceAll(value, ",", "."), 64)
+}
+
+// parseDistance reads an optional distance in the given unit as meters
+func parseDistance(value string, metersPerUnit float64) (*float64, error) {
+	distance, err := parseNumber(value)
+	if err != nil || distance < 0 {
+		return nil, fmt.Errorf("invalid distance %q", value)
+	}
+	if distance == 0 {
+		return nil, nil
+	}
+	meters := math.Round(distance*metersPerUnit*100) / 100
+	return &meters, nil
+}
+
+// parseSeconds reads an optional duration in seconds
+func parseSeconds(value string) (*int, error) {
+	seconds, err := parseNumber(value)
+	if err != nil || seconds < 0 {
+		return nil, fmt.Errorf("invalid duration %q", value)
+	}
+	if seconds == 0 {
+		return nil, nil
+	}
+	rounded := int(math.Round(seconds))
+	return &rounded, nil
+}
+
+// parseRPE reads an optional RPE, ignoring values outside the 1-10 scale
+func parseRPE(value string) *float64 {
+	rpe, err := parseNumber(value)
+	if err != nil || rpe < 1 || rpe > 10 {
+		return nil
+	}
+	return &rpe
+}
+
+// parseTime reads a timestamp in the first layout that fits
+func parseTime(value string, loc *time.Location, layouts ...string) (time.Time, error) {
+	for _, layout := range layouts {
+		if t, err := time.ParseInLocation(layout, value, loc); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognised date %q", value)
+}
@@ -0,0 +1,136 @@
+package imports
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rachitnimje/trackle-web/models"
+)
+
+// strongDurationPattern matches Strong's durations such as "1h 5m" or "45m"
+var strongDurationPattern = regexp.MustCompile(`^(?:(\d+)h)?\s*(?:(\d+)m)?\s*(?:(\d+)s)?$`)
+
+// strongParser reads the CSV export of the Strong app: one row per set, with
+// the workout repeated on every row. Weights are in the unit set in the app and
+// distances in kilometres, unless a distance unit column says miles.
+type strongParser struct {
+	loc *time.Location
+}
+
+func (p *strongParser) check(columns map[string]int) error {
+	return requireColumns(columns, "date", "workout name", "exercise name", "set order", "weight", "reps")
+}
+
+func (p *strongParser) parse(r row) (string, Workout, *Set, string) {
+	date := r.get("date")
+	name := r.get("workout name")
+
+	startedAt, err := parseTime(date, p.loc, "2006-01-02 15:04:05", "2006-01-02 15:04", time.RFC3339)
+	if err != nil {
+		return "", Workout{}, nil, err.Error()
+	}
+
+	workout := Workout{
+		Name:      name,
+		StartedAt: startedAt,
+		Notes:     r.get("workout notes"),
+	}
+	if duration, ok := parseStrongDuration(r.get("duration")); ok && duration > 0 {
+		finishedAt := startedAt.Add(duration)
+		workout.FinishedAt = &finishedAt
+	}
+	key := date + "|" + name
+
+	// rest timers and notes are exported as rows of their own
+	setType, ok := strongSetType(r.get("set order"))
+	if !ok {
+		return key, workout, nil, ""
+	}
+
+	exercise := r.get("exercise name")
+	if exercise == "" {
+		return key, workout, nil, "skipped a set without an exercise name"
+	}
+	reps, err := parseNumber(r.get("reps"))
+	if err != nil {
+		return key, workout, nil, fmt.Sprintf("invalid reps %q", r.get("reps"))
+	}
+
+	durationSeconds, err := parseSeconds(r.get("seconds"))
+	if err != nil {
+		return key, workout, nil, err.Error()
+	}
+	metersPerUnit := float64(metersPerKilometre)
+	if unit := strings.ToLower(r.get("distance unit")); unit == "mi" || unit == "miles" {
+		metersPerUnit = metersPerMile
+	}
+	distanceMeters, err := parseDistance(r.get("distance"), metersPerUnit)
+	if err != nil {
+		return key, workout, nil, err.Error()
+	}
+
+	if reps <= 0 && durationSeconds == nil && distanceMeters == nil {
+		return key, workout, nil, fmt.Sprintf("skipped a set of %q without reps, time or distance", exercise)
+	}
+
+	weight, err := parseNumber(r.get("weight"))
+	if err != nil || weight < 0 {
+		return key, workout, nil, fmt.Sprintf("invalid weight %q", r.get("weight"))
+	}
+
+	return key, workout, &Set{
+		ExerciseName:    exercise,
+		SetType:         setType,
+		Reps:            int(math.Round(max(reps, 0))),
+		Weight:          weight,
+		DurationSeconds: durationSeconds,
+		DistanceMeters:  distanceMeters,
+		RPE:             parseRPE(r.get("rpe")),
+		Note:            r.get("notes"),
+	}, ""
+}
+
+// strongSetType reads the set type from a set order, which is either the set's
+// number or a marker for warm-up, drop and failure sets. It reports false for
+// rows that are not sets.
+func strongSetType(setOrder string) (string, bool) {
+	if _, err := strconv.Atoi(setOrder); err == nil {
+		return models.SetTypeWorking, true
+	}
+	switch strings.ToUpper(setOrder) {
+	case "W":
+		return models.SetTypeWarmup, true
+	case "D":
+		return models.SetTypeDrop, true
+	case "F":
+		return models.SetTypeFailure, true
+	}
+	return "", false
+}
+
+// parseStrongDuration reads "1h 5m" style durations, or a number of seconds
+func parseStrongDuration(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	match := strongDurationPattern.FindStringSubmatch(value)
+	if match == nil {
+		return 0, false
+	}
+	var duration time.Duration
+	for i, unit := range []time.Duration{time.Hour, time.Minute, time.Second} {
+		if match[i+1] != "" {
+			n, _ := strconv.Atoi(match[i+1])
+			duration += time.Duration(n) * unit
+		}
+	}
+	return duration, true
+}
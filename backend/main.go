@@ -1,28 +1,88 @@
 package main
 
 import (
-	"log"
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/joho/godotenv"
 	"github.com/rachitnimje/trackle-web/config"
+	"github.com/rachitnimje/trackle-web/metrics"
 	"github.com/rachitnimje/trackle-web/middleware"
 	"github.com/rachitnimje/trackle-web/routes"
+	"github.com/rachitnimje/trackle-web/tracing"
 	"github.com/rachitnimje/trackle-web/utils"
 )
 
 func main() {
-	err := godotenv.Load()
+	// Handle the migrate subcommand instead of starting the server
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		runMigrate(os.Args[2:])
+		return
+	}
+
+	// Log JSON lines from the start; the level is configured once loaded
+	slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stdout, nil)))
+
+	// Load configuration from the environment, .env and CONFIG_FILE
+	cfg, err := config.Load()
+	if err != nil {
+		fatal("Failed to load configuration", err)
+	}
+
+	logger, err := utils.NewLogger(os.Stdout, cfg.Log.Level)
+	if err != nil {
+		fatal("Failed to create logger", err)
+	}
+	slog.SetDefault(logger)
+	slog.Info("Loaded configuration", "config", cfg)
+
+	if cfg.IsProduction() {
+		gin.SetMode(gin.ReleaseMode)
+	}
+
+	// Export traces; spans are flushed on shutdown
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing)
 	if err != nil {
-		log.Fatal("Error loading .env file")
+		fatal("Failed to set up tracing", err)
 	}
 
 	// Connect to database
-	db := config.ConnectDB()
+	db, err := config.ConnectDB(cfg.Database)
+	if err != nil {
+		fatal("Failed to connect to database", err)
+	}
+
+	// Trace and time database statements and export the connection pool
+	// statistics
+	if err := db.Use(tracing.GormPlugin{}); err != nil {
+		fatal("Failed to register database tracing", err)
+	}
+	if err := db.Use(metrics.GormPlugin{}); err != nil {
+		fatal("Failed to register database metrics", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		fatal("Failed to access database connection pool", err)
+	}
+	if err := metrics.RegisterDBStats(sqlDB); err != nil {
+		fatal("Failed to register database metrics", err)
+	}
 
 	// Run migrations
-	config.MigrateDB(db)
+	if err := config.MigrateDB(db); err != nil {
+		fatal("Failed to run migrations", err)
+	}
+
+	// Seed built-in roles and permissions
+	if err := config.SeedRoles(db); err != nil {
+		fatal("Failed to seed roles", err)
+	}
 
 	// Initialize validator
 	utils.InitValidator()
@@ -32,25 +92,72 @@ func main() {
 
 	// Add recovery middleware to handle panics
 	r.Use(gin.Recovery())
-	
+
+	// Tag requests with an ID and a trace span and log them once handled,
+	// including panics
+	r.Use(middleware.RequestIDMiddleware())
+	r.Use(middleware.TracingMiddleware())
+	r.Use(middleware.LoggerMiddleware())
+	r.Use(middleware.MetricsMiddleware())
+
 	// Add custom recovery middleware for better error handling
 	r.Use(middleware.ErrorRecoveryMiddleware())
 
 	// Add middleware
-	r.Use(middleware.CORSMiddleware())
-	r.Use(middleware.LoggerMiddleware())
+	r.Use(middleware.CORSMiddleware(cfg.CORS.AllowedOrigins))
 
 	// Setup routes
-	routes.SetupRoutes(r, db)
+	routes.SetupRoutes(r, db, cfg)
+
+	srv := &http.Server{
+		Addr:              ":" + cfg.Server.Port,
+		Handler:           r,
+		ReadTimeout:       time.Duration(cfg.Server.ReadTimeout),
+		ReadHeaderTimeout: time.Duration(cfg.Server.ReadHeaderTimeout),
+		WriteTimeout:      time.Duration(cfg.Server.WriteTimeout),
+		IdleTimeout:       time.Duration(cfg.Server.IdleTimeout),
+	}
+
+	// Stop accepting connections on SIGINT or SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	serverErr := make(chan error, 1)
+	go func() {
+		slog.Info("Server starting", "port", cfg.Server.Port)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serverErr <- err
+		}
+	}()
 
-	// Get port from environment or default to 8080
-	port := os.Getenv("SERVER_PORT")
-	if port == "" {
-		port = "8080"
+	select {
+	case err := <-serverErr:
+		fatal("Failed to start server", err)
+	case <-ctx.Done():
 	}
+	stop()
 
-	log.Printf("Server starting on port %s", port)
-	if err := r.Run(":" + port); err != nil {
-		log.Fatal("Failed to start server:", err)
+	// Let in-flight requests and their transactions finish before closing the
+	// database connections
+	slog.Info("Shutting down, waiting for in-flight requests", "timeout", cfg.Server.ShutdownTimeout.String())
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.Server.ShutdownTimeout))
+	defer cancel()
+
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		slog.Error("Server shutdown did not complete", "error", err.Error())
 	}
+
+	if err := sqlDB.Close(); err != nil {
+		slog.Error("Failed to close database", "error", err.Error())
+	}
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		slog.Error("Failed to flush traces", "error", err.Error())
+	}
+	slog.Info("Server stopped")
+}
+
+// fatal logs an error that prevents the server from running and exits
+func fatal(message string, err error) {
+	slog.Error(message, "error", err.Error())
+	os.Exit(1)
 }
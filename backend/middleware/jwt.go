@@ -2,11 +2,16 @@ package middleware
 
 import (
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/rachitnimje/trackle-web/models"
 	"github.com/rachitnimje/trackle-web/utils"
 )
 
-func AuthMiddleware() gin.HandlerFunc {
+func AuthMiddleware(db *gorm.DB, jwtManager *utils.JWTManager) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
 		// Try to get token from cookie first
 		token, err := c.Cookie("auth_token")
 		if err != nil {
@@ -31,7 +36,7 @@ func AuthMiddleware() gin.HandlerFunc {
 		}
 
 		// Validate the token
-		claims, err := utils.ValidateJWT(token)
+		claims, err := jwtManager.Validate(token)
 		if err != nil {
 			appErr := utils.NewAuthenticationError("Invalid or expired token", err)
 			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
@@ -39,8 +44,28 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		// Set user ID in context for use in handlers
+		// Reject tokens whose session was revoked by logout or refresh token reuse
+		var activeTokens int64
+		if err := db.Model(&models.RefreshToken{}).
+			Where("family_id = ? AND revoked_at IS NULL", claims.SessionID).
+			Count(&activeTokens).Error; err != nil {
+			appErr := utils.NewDatabaseError("Failed to verify session", err)
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			c.Abort()
+			return
+		}
+
+		if activeTokens == 0 {
+			appErr := utils.NewAuthenticationError("Session has been revoked", nil)
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			c.Abort()
+			return
+		}
+
+		// Set user, role and session IDs in context for use in handlers
 		c.Set("user_id", claims.UserID)
+		c.Set("role", claims.Role)
+		c.Set("session_id", claims.SessionID)
 		c.Next()
 	}
 }
\ No newline at end of file
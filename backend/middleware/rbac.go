@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/rachitnimje/trackle-web/utils"
+)
+
+// RequireRole only lets the request through if the authenticated user has one
+// of the given roles. It must run after AuthMiddleware.
+func RequireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role := c.GetString("role")
+		for _, allowed := range roles {
+			if role == allowed {
+				c.Next()
+				return
+			}
+		}
+
+		appErr := utils.NewAuthorizationError("You do not have permission to perform this action", nil)
+		utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+		c.Abort()
+	}
+}
+
+// RequirePermission only lets the request through if the authenticated user's
+// role has been granted the given permission. It must run after AuthMiddleware.
+func RequirePermission(db *gorm.DB, permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
+		granted, err := utils.HasPermission(db, c.GetString("role"), permission)
+		if err != nil {
+			appErr := utils.NewDatabaseError("Failed to verify permissions", err)
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			c.Abort()
+			return
+		}
+
+		if !granted {
+			appErr := utils.NewAuthorizationError("You do not have permission to perform this action", nil)
+			utils.ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
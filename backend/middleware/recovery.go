@@ -14,19 +14,21 @@ func ErrorRecoveryMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		defer func() {
 			if err := recover(); err != nil {
-				// Log the stack trace
-				stack := debug.Stack()
-				fmt.Printf("[PANIC RECOVERED] %v\n%s\n", err, stack)
+				// Log the panic with its stack trace
+				utils.RequestLogger(c).Error("panic recovered",
+					"panic", fmt.Sprint(err),
+					"stack", string(debug.Stack()),
+				)
 
 				// Create a clean error for the client
 				appErr := utils.NewInternalError("An unexpected error occurred", fmt.Errorf("%v", err))
 				utils.ErrorResponse(c, appErr.StatusCode, "Server Error", appErr)
-				
+
 				// Abort the request chain
 				c.Abort()
 			}
 		}()
-		
+
 		c.Next()
 	}
 }
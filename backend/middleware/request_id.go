@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"github.com/rachitnimje/trackle-web/utils"
+)
+
+// RequestIDMiddleware gives every request an ID, reusing a valid X-Request-ID
+// sent by the client or a proxy, and echoes it in the response
+func RequestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader(utils.RequestIDHeader)
+		if !utils.ValidRequestID(requestID) {
+			var err error
+			requestID, err = utils.GenerateRequestID()
+			if err != nil {
+				requestID = ""
+			}
+		}
+
+		if requestID != "" {
+			c.Set("request_id", requestID)
+			c.Header(utils.RequestIDHeader, requestID)
+		}
+		c.Next()
+	}
+}
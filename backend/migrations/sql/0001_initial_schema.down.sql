@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS workout_entries;
+DROP TABLE IF EXISTS workouts;
+DROP TABLE IF EXISTS template_exercises;
+DROP TABLE IF EXISTS templates;
+DROP TABLE IF EXISTS exercises;
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,87 @@
+-- Baseline schema. IF NOT EXISTS lets databases created by the old
+-- AutoMigrate-based boot adopt the migration history unchanged.
+
+CREATE TABLE IF NOT EXISTS users (
+    id bigserial PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    username text NOT NULL,
+    email text NOT NULL,
+    password text NOT NULL,
+    role text NOT NULL DEFAULT 'user',
+    CONSTRAINT uni_users_username UNIQUE (username),
+    CONSTRAINT uni_users_email UNIQUE (email)
+);
+CREATE INDEX IF NOT EXISTS idx_users_deleted_at ON users (deleted_at);
+
+CREATE TABLE IF NOT EXISTS exercises (
+    id bigserial PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    name text NOT NULL,
+    description text,
+    category text,
+    primary_muscle text,
+    equipment text
+);
+CREATE INDEX IF NOT EXISTS idx_exercises_deleted_at ON exercises (deleted_at);
+
+CREATE TABLE IF NOT EXISTS templates (
+    id bigserial PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    name text NOT NULL,
+    description text,
+    user_id bigint NOT NULL,
+    CONSTRAINT fk_templates_user FOREIGN KEY (user_id) REFERENCES users (id)
+);
+CREATE INDEX IF NOT EXISTS idx_templates_deleted_at ON templates (deleted_at);
+
+CREATE TABLE IF NOT EXISTS template_exercises (
+    id bigserial PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    template_id bigint NOT NULL,
+    exercise_id bigint NOT NULL,
+    sets bigint NOT NULL,
+    CONSTRAINT chk_template_exercises_sets CHECK (sets > 0),
+    CONSTRAINT fk_templates_exercises FOREIGN KEY (template_id) REFERENCES templates (id),
+    CONSTRAINT fk_template_exercises_exercise FOREIGN KEY (exercise_id) REFERENCES exercises (id)
+);
+CREATE INDEX IF NOT EXISTS idx_template_exercises_deleted_at ON template_exercises (deleted_at);
+
+CREATE TABLE IF NOT EXISTS workouts (
+    id bigserial PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    user_id bigint NOT NULL,
+    template_id bigint NOT NULL,
+    name text NOT NULL,
+    notes text,
+    CONSTRAINT fk_workouts_user FOREIGN KEY (user_id) REFERENCES users (id),
+    CONSTRAINT fk_workouts_template FOREIGN KEY (template_id) REFERENCES templates (id)
+);
+CREATE INDEX IF NOT EXISTS idx_workouts_deleted_at ON workouts (deleted_at);
+
+CREATE TABLE IF NOT EXISTS workout_entries (
+    id bigserial PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    workout_id bigint NOT NULL,
+    exercise_id bigint NOT NULL,
+    set_number bigint NOT NULL,
+    reps bigint NOT NULL,
+    weight decimal NOT NULL,
+    CONSTRAINT chk_workout_entries_set_number CHECK (set_number > 0),
+    CONSTRAINT chk_workout_entries_reps CHECK (reps > 0),
+    CONSTRAINT chk_workout_entries_weight CHECK (weight >= 0),
+    CONSTRAINT fk_workouts_entries FOREIGN KEY (workout_id) REFERENCES workouts (id),
+    CONSTRAINT fk_workout_entries_exercise FOREIGN KEY (exercise_id) REFERENCES exercises (id)
+);
+CREATE INDEX IF NOT EXISTS idx_workout_entries_deleted_at ON workout_entries (deleted_at);
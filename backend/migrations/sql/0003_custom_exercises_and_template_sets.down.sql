@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS template_sets;
+ALTER TABLE template_exercises DROP COLUMN IF EXISTS position;
+ALTER TABLE exercises DROP COLUMN IF EXISTS user_id;
@@ -0,0 +1,23 @@
+ALTER TABLE exercises ADD COLUMN IF NOT EXISTS user_id bigint REFERENCES users (id);
+CREATE INDEX IF NOT EXISTS idx_exercises_user_id ON exercises (user_id);
+
+ALTER TABLE template_exercises ADD COLUMN IF NOT EXISTS position bigint NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS template_sets (
+    id bigserial PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    template_exercise_id bigint NOT NULL,
+    set_number bigint NOT NULL,
+    target_reps bigint,
+    target_weight decimal,
+    rest_seconds bigint,
+    CONSTRAINT chk_template_sets_set_number CHECK (set_number > 0),
+    CONSTRAINT chk_template_sets_target_reps CHECK (target_reps > 0),
+    CONSTRAINT chk_template_sets_target_weight CHECK (target_weight >= 0),
+    CONSTRAINT chk_template_sets_rest_seconds CHECK (rest_seconds >= 0),
+    CONSTRAINT fk_template_exercises_set_targets FOREIGN KEY (template_exercise_id) REFERENCES template_exercises (id)
+);
+CREATE INDEX IF NOT EXISTS idx_template_sets_deleted_at ON template_sets (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_template_sets_template_exercise_id ON template_sets (template_exercise_id);
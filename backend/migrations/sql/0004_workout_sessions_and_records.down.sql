@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS personal_records;
+ALTER TABLE workouts
+    DROP COLUMN IF EXISTS duration_minutes,
+    DROP COLUMN IF EXISTS finished_at,
+    DROP COLUMN IF EXISTS started_at;
@@ -0,0 +1,25 @@
+ALTER TABLE workouts
+    ADD COLUMN IF NOT EXISTS started_at timestamptz,
+    ADD COLUMN IF NOT EXISTS finished_at timestamptz,
+    ADD COLUMN IF NOT EXISTS duration_minutes bigint;
+
+CREATE TABLE IF NOT EXISTS personal_records (
+    id bigserial PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    user_id bigint NOT NULL,
+    exercise_id bigint NOT NULL,
+    workout_id bigint NOT NULL,
+    workout_entry_id bigint,
+    type text NOT NULL,
+    value decimal NOT NULL,
+    weight decimal,
+    reps bigint,
+    achieved_at timestamptz NOT NULL,
+    is_current boolean NOT NULL DEFAULT false,
+    CONSTRAINT fk_personal_records_exercise FOREIGN KEY (exercise_id) REFERENCES exercises (id)
+);
+CREATE INDEX IF NOT EXISTS idx_personal_records_deleted_at ON personal_records (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_personal_records_user_exercise ON personal_records (user_id, exercise_id);
+CREATE INDEX IF NOT EXISTS idx_personal_records_workout_id ON personal_records (workout_id);
@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS idx_workouts_active;
+DROP INDEX IF EXISTS idx_role_permissions_permission_id;
+DROP INDEX IF EXISTS idx_workout_entries_exercise_id;
+DROP INDEX IF EXISTS idx_workout_entries_workout_id;
+DROP INDEX IF EXISTS idx_workouts_template_id;
+DROP INDEX IF EXISTS idx_workouts_user_id_created_at;
+DROP INDEX IF EXISTS idx_template_exercises_exercise_id;
+DROP INDEX IF EXISTS idx_template_exercises_template_id;
+DROP INDEX IF EXISTS idx_templates_user_id;
@@ -0,0 +1,13 @@
+-- Indexes on foreign keys and frequently filtered columns (see optimizations.md)
+CREATE INDEX IF NOT EXISTS idx_templates_user_id ON templates (user_id);
+CREATE INDEX IF NOT EXISTS idx_template_exercises_template_id ON template_exercises (template_id);
+CREATE INDEX IF NOT EXISTS idx_template_exercises_exercise_id ON template_exercises (exercise_id);
+CREATE INDEX IF NOT EXISTS idx_workouts_user_id_created_at ON workouts (user_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_workouts_template_id ON workouts (template_id);
+CREATE INDEX IF NOT EXISTS idx_workout_entries_workout_id ON workout_entries (workout_id);
+CREATE INDEX IF NOT EXISTS idx_workout_entries_exercise_id ON workout_entries (exercise_id);
+CREATE INDEX IF NOT EXISTS idx_role_permissions_permission_id ON role_permissions (permission_id);
+
+-- Looking up a user's session in progress
+CREATE INDEX IF NOT EXISTS idx_workouts_active ON workouts (user_id)
+    WHERE started_at IS NOT NULL AND finished_at IS NULL;
@@ -0,0 +1,2 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS weight_unit text NOT NULL DEFAULT 'kg'
+    CONSTRAINT chk_users_weight_unit CHECK (weight_unit IN ('kg', 'lb'));
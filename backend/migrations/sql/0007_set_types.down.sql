@@ -0,0 +1,5 @@
+ALTER TABLE workout_entries
+    DROP COLUMN IF EXISTS note,
+    DROP COLUMN IF EXISTS rir,
+    DROP COLUMN IF EXISTS rpe,
+    DROP COLUMN IF EXISTS set_type;
@@ -0,0 +1,8 @@
+ALTER TABLE workout_entries
+    ADD COLUMN IF NOT EXISTS set_type text NOT NULL DEFAULT 'working'
+        CONSTRAINT chk_workout_entries_set_type CHECK (set_type IN ('warmup', 'working', 'drop', 'failure', 'amrap')),
+    ADD COLUMN IF NOT EXISTS rpe decimal
+        CONSTRAINT chk_workout_entries_rpe CHECK (rpe BETWEEN 1 AND 10),
+    ADD COLUMN IF NOT EXISTS rir bigint
+        CONSTRAINT chk_workout_entries_rir CHECK (rir >= 0),
+    ADD COLUMN IF NOT EXISTS note text;
@@ -0,0 +1,11 @@
+-- timed and distance sets cannot be stored without the new columns
+DELETE FROM workout_entries WHERE reps = 0;
+
+ALTER TABLE workout_entries
+    DROP COLUMN IF EXISTS distance_meters,
+    DROP COLUMN IF EXISTS duration_seconds,
+    DROP CONSTRAINT IF EXISTS chk_workout_entries_reps,
+    ALTER COLUMN reps DROP DEFAULT;
+ALTER TABLE workout_entries ADD CONSTRAINT chk_workout_entries_reps CHECK (reps > 0);
+
+ALTER TABLE exercises DROP COLUMN IF EXISTS tracking_type;
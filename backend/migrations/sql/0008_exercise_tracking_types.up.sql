@@ -0,0 +1,12 @@
+ALTER TABLE exercises ADD COLUMN IF NOT EXISTS tracking_type text NOT NULL DEFAULT 'weight_reps'
+    CONSTRAINT chk_exercises_tracking_type CHECK (tracking_type IN ('weight_reps', 'reps_only', 'duration', 'distance_duration'));
+
+-- timed and distance sets have no reps
+ALTER TABLE workout_entries DROP CONSTRAINT IF EXISTS chk_workout_entries_reps;
+ALTER TABLE workout_entries
+    ALTER COLUMN reps SET DEFAULT 0,
+    ADD CONSTRAINT chk_workout_entries_reps CHECK (reps >= 0),
+    ADD COLUMN IF NOT EXISTS duration_seconds bigint
+        CONSTRAINT chk_workout_entries_duration_seconds CHECK (duration_seconds > 0),
+    ADD COLUMN IF NOT EXISTS distance_meters decimal
+        CONSTRAINT chk_workout_entries_distance_meters CHECK (distance_meters > 0);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_workouts_user_id_performed_at;
+ALTER TABLE workouts DROP COLUMN IF EXISTS performed_at;
+ALTER TABLE users DROP COLUMN IF EXISTS timezone;
@@ -0,0 +1,11 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS timezone text NOT NULL DEFAULT 'UTC';
+
+-- Existing workouts were dated by when they were logged, or started for live sessions
+ALTER TABLE workouts ADD COLUMN IF NOT EXISTS performed_at timestamptz;
+UPDATE workouts SET performed_at = COALESCE(started_at, created_at, now()) WHERE performed_at IS NULL;
+ALTER TABLE workouts
+    ALTER COLUMN performed_at SET NOT NULL,
+    ALTER COLUMN performed_at SET DEFAULT now();
+
+-- History and statistics are read by date performed
+CREATE INDEX IF NOT EXISTS idx_workouts_user_id_performed_at ON workouts (user_id, performed_at);
@@ -0,0 +1,8 @@
+ALTER TABLE template_exercises
+    DROP CONSTRAINT IF EXISTS chk_template_exercises_rep_range,
+    DROP COLUMN IF EXISTS deload_percent,
+    DROP COLUMN IF EXISTS deload_after,
+    DROP COLUMN IF EXISTS rep_range_max,
+    DROP COLUMN IF EXISTS rep_range_min,
+    DROP COLUMN IF EXISTS progression_increment,
+    DROP COLUMN IF EXISTS progression_type;
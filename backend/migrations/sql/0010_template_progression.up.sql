@@ -0,0 +1,16 @@
+ALTER TABLE template_exercises
+    ADD COLUMN IF NOT EXISTS progression_type text NOT NULL DEFAULT 'none'
+        CONSTRAINT chk_template_exercises_progression_type CHECK (progression_type IN ('none', 'linear', 'double')),
+    ADD COLUMN IF NOT EXISTS progression_increment decimal
+        CONSTRAINT chk_template_exercises_progression_increment CHECK (progression_increment > 0),
+    ADD COLUMN IF NOT EXISTS rep_range_min bigint
+        CONSTRAINT chk_template_exercises_rep_range_min CHECK (rep_range_min > 0),
+    ADD COLUMN IF NOT EXISTS rep_range_max bigint,
+    ADD COLUMN IF NOT EXISTS deload_after bigint
+        CONSTRAINT chk_template_exercises_deload_after CHECK (deload_after > 0),
+    ADD COLUMN IF NOT EXISTS deload_percent decimal
+        CONSTRAINT chk_template_exercises_deload_percent CHECK (deload_percent > 0 AND deload_percent < 100);
+
+ALTER TABLE template_exercises DROP CONSTRAINT IF EXISTS chk_template_exercises_rep_range;
+ALTER TABLE template_exercises
+    ADD CONSTRAINT chk_template_exercises_rep_range CHECK (rep_range_max >= rep_range_min);
@@ -0,0 +1,98 @@
+CREATE TABLE IF NOT EXISTS programs (
+    id bigserial PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    name text NOT NULL,
+    description text,
+    user_id bigint NOT NULL,
+    CONSTRAINT fk_programs_user FOREIGN KEY (user_id) REFERENCES users (id)
+);
+CREATE INDEX IF NOT EXISTS idx_programs_deleted_at ON programs (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_programs_user_id ON programs (user_id);
+
+CREATE TABLE IF NOT EXISTS program_weeks (
+    id bigserial PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    program_id bigint NOT NULL,
+    week_number bigint NOT NULL,
+    name text,
+    CONSTRAINT chk_program_weeks_week_number CHECK (week_number > 0),
+    CONSTRAINT fk_programs_weeks FOREIGN KEY (program_id) REFERENCES programs (id)
+);
+CREATE INDEX IF NOT EXISTS idx_program_weeks_deleted_at ON program_weeks (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_program_weeks_program_id ON program_weeks (program_id);
+
+CREATE TABLE IF NOT EXISTS program_days (
+    id bigserial PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    program_week_id bigint NOT NULL,
+    day_number bigint NOT NULL,
+    name text,
+    template_id bigint,
+    CONSTRAINT chk_program_days_day_number CHECK (day_number > 0),
+    CONSTRAINT fk_program_weeks_days FOREIGN KEY (program_week_id) REFERENCES program_weeks (id),
+    CONSTRAINT fk_program_days_template FOREIGN KEY (template_id) REFERENCES templates (id)
+);
+CREATE INDEX IF NOT EXISTS idx_program_days_deleted_at ON program_days (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_program_days_program_week_id ON program_days (program_week_id);
+CREATE INDEX IF NOT EXISTS idx_program_days_template_id ON program_days (template_id);
+
+CREATE TABLE IF NOT EXISTS program_sets (
+    id bigserial PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    program_day_id bigint NOT NULL,
+    exercise_id bigint NOT NULL,
+    set_number bigint NOT NULL,
+    percent decimal NOT NULL,
+    reps bigint NOT NULL,
+    amrap boolean NOT NULL DEFAULT false,
+    CONSTRAINT chk_program_sets_set_number CHECK (set_number > 0),
+    CONSTRAINT chk_program_sets_percent CHECK (percent > 0),
+    CONSTRAINT chk_program_sets_reps CHECK (reps > 0),
+    CONSTRAINT fk_program_days_sets FOREIGN KEY (program_day_id) REFERENCES program_days (id),
+    CONSTRAINT fk_program_sets_exercise FOREIGN KEY (exercise_id) REFERENCES exercises (id)
+);
+CREATE INDEX IF NOT EXISTS idx_program_sets_deleted_at ON program_sets (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_program_sets_program_day_id ON program_sets (program_day_id);
+
+CREATE TABLE IF NOT EXISTS program_enrollments (
+    id bigserial PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    user_id bigint NOT NULL,
+    program_id bigint NOT NULL,
+    current_week bigint NOT NULL DEFAULT 1,
+    current_day bigint NOT NULL DEFAULT 1,
+    started_at timestamptz NOT NULL,
+    completed_at timestamptz,
+    CONSTRAINT fk_program_enrollments_user FOREIGN KEY (user_id) REFERENCES users (id),
+    CONSTRAINT fk_program_enrollments_program FOREIGN KEY (program_id) REFERENCES programs (id)
+);
+CREATE INDEX IF NOT EXISTS idx_program_enrollments_deleted_at ON program_enrollments (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_program_enrollments_program_id ON program_enrollments (program_id);
+-- A user runs at most one program at a time
+CREATE UNIQUE INDEX IF NOT EXISTS idx_program_enrollments_active ON program_enrollments (user_id)
+    WHERE completed_at IS NULL AND deleted_at IS NULL;
+
+CREATE TABLE IF NOT EXISTS program_training_maxes (
+    id bigserial PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    program_enrollment_id bigint NOT NULL,
+    exercise_id bigint NOT NULL,
+    weight decimal NOT NULL,
+    CONSTRAINT chk_program_training_maxes_weight CHECK (weight > 0),
+    CONSTRAINT fk_program_enrollments_training_maxes FOREIGN KEY (program_enrollment_id) REFERENCES program_enrollments (id),
+    CONSTRAINT fk_program_training_maxes_exercise FOREIGN KEY (exercise_id) REFERENCES exercises (id)
+);
+CREATE INDEX IF NOT EXISTS idx_program_training_maxes_deleted_at ON program_training_maxes (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_program_training_maxes_program_enrollment_id ON program_training_maxes (program_enrollment_id);
@@ -0,0 +1,9 @@
+ALTER TABLE workout_entries
+    DROP CONSTRAINT IF EXISTS chk_workout_entries_group,
+    DROP COLUMN IF EXISTS group_type,
+    DROP COLUMN IF EXISTS group_id;
+ALTER TABLE template_exercises
+    DROP CONSTRAINT IF EXISTS chk_template_exercises_group,
+    DROP COLUMN IF EXISTS group_rounds,
+    DROP COLUMN IF EXISTS group_type,
+    DROP COLUMN IF EXISTS group_id;
@@ -0,0 +1,23 @@
+ALTER TABLE template_exercises
+    ADD COLUMN IF NOT EXISTS group_id bigint
+        CONSTRAINT chk_template_exercises_group_id CHECK (group_id > 0),
+    ADD COLUMN IF NOT EXISTS group_type text
+        CONSTRAINT chk_template_exercises_group_type CHECK (group_type IN ('superset', 'circuit', 'giant_set')),
+    ADD COLUMN IF NOT EXISTS group_rounds bigint
+        CONSTRAINT chk_template_exercises_group_rounds CHECK (group_rounds > 0);
+
+ALTER TABLE template_exercises DROP CONSTRAINT IF EXISTS chk_template_exercises_group;
+ALTER TABLE template_exercises
+    ADD CONSTRAINT chk_template_exercises_group CHECK (
+        (group_id IS NULL) = (group_type IS NULL) AND (group_id IS NULL) = (group_rounds IS NULL)
+    );
+
+ALTER TABLE workout_entries
+    ADD COLUMN IF NOT EXISTS group_id bigint
+        CONSTRAINT chk_workout_entries_group_id CHECK (group_id > 0),
+    ADD COLUMN IF NOT EXISTS group_type text
+        CONSTRAINT chk_workout_entries_group_type CHECK (group_type IN ('superset', 'circuit', 'giant_set'));
+
+ALTER TABLE workout_entries DROP CONSTRAINT IF EXISTS chk_workout_entries_group;
+ALTER TABLE workout_entries
+    ADD CONSTRAINT chk_workout_entries_group CHECK ((group_id IS NULL) = (group_type IS NULL));
@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS idx_templates_public;
+DROP INDEX IF EXISTS idx_templates_source_template_id;
+DROP INDEX IF EXISTS idx_templates_share_token;
+ALTER TABLE templates
+    DROP CONSTRAINT IF EXISTS chk_templates_share_token,
+    DROP CONSTRAINT IF EXISTS fk_templates_source,
+    DROP COLUMN IF EXISTS source_template_id,
+    DROP COLUMN IF EXISTS share_token,
+    DROP COLUMN IF EXISTS visibility;
@@ -0,0 +1,20 @@
+ALTER TABLE templates
+    ADD COLUMN IF NOT EXISTS visibility text NOT NULL DEFAULT 'private'
+        CONSTRAINT chk_templates_visibility CHECK (visibility IN ('private', 'unlisted', 'public')),
+    ADD COLUMN IF NOT EXISTS share_token text,
+    ADD COLUMN IF NOT EXISTS source_template_id bigint;
+
+ALTER TABLE templates DROP CONSTRAINT IF EXISTS fk_templates_source;
+ALTER TABLE templates
+    ADD CONSTRAINT fk_templates_source FOREIGN KEY (source_template_id) REFERENCES templates (id);
+
+-- shared templates are reached through their share link
+ALTER TABLE templates DROP CONSTRAINT IF EXISTS chk_templates_share_token;
+ALTER TABLE templates
+    ADD CONSTRAINT chk_templates_share_token CHECK (visibility = 'private' OR share_token IS NOT NULL);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_templates_share_token ON templates (share_token);
+CREATE INDEX IF NOT EXISTS idx_templates_source_template_id ON templates (source_template_id);
+-- the public catalog only lists public templates
+CREATE INDEX IF NOT EXISTS idx_templates_public ON templates (created_at)
+    WHERE visibility = 'public' AND deleted_at IS NULL;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_workouts_active;
+CREATE INDEX IF NOT EXISTS idx_workouts_active ON workouts (user_id)
+    WHERE started_at IS NOT NULL AND finished_at IS NULL;
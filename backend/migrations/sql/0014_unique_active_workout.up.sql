@@ -0,0 +1,19 @@
+-- A user has at most one session in progress. Older duplicate sessions left by
+-- concurrent starts are finished so the unique index can be built.
+UPDATE workouts w
+SET finished_at = COALESCE(w.updated_at, w.started_at)
+WHERE w.started_at IS NOT NULL
+  AND w.finished_at IS NULL
+  AND w.deleted_at IS NULL
+  AND EXISTS (
+      SELECT 1 FROM workouts newer
+      WHERE newer.user_id = w.user_id
+        AND newer.started_at IS NOT NULL
+        AND newer.finished_at IS NULL
+        AND newer.deleted_at IS NULL
+        AND (newer.started_at, newer.id) > (w.started_at, w.id)
+  );
+
+DROP INDEX IF EXISTS idx_workouts_active;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_workouts_active ON workouts (user_id)
+    WHERE started_at IS NOT NULL AND finished_at IS NULL AND deleted_at IS NULL;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_exercises_user_name;
+DROP INDEX IF EXISTS idx_exercises_system_name;
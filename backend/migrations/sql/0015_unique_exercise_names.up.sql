@@ -0,0 +1,18 @@
+-- Exercise names are unique within the system catalog and within each user's
+-- custom exercises. Older duplicates get their ID appended so the unique
+-- indexes can be built; workouts and templates keep referencing them by ID.
+UPDATE exercises e
+SET name = e.name || ' (' || e.id || ')'
+WHERE e.deleted_at IS NULL
+  AND EXISTS (
+      SELECT 1 FROM exercises older
+      WHERE older.user_id IS NOT DISTINCT FROM e.user_id
+        AND older.name = e.name
+        AND older.deleted_at IS NULL
+        AND older.id < e.id
+  );
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_exercises_system_name ON exercises (name)
+    WHERE user_id IS NULL AND deleted_at IS NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_exercises_user_name ON exercises (user_id, name)
+    WHERE user_id IS NOT NULL AND deleted_at IS NULL;
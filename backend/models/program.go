@@ -0,0 +1,77 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Program is a multi-week training plan. Each of its ordered weeks holds
+// ordered days, and each day runs one of the owner's templates or is a rest day.
+type Program struct {
+	gorm.Model
+	Name        string        `json:"name" gorm:"not null"`
+	Description string        `json:"description"`
+	UserID      uint          `json:"user_id" gorm:"not null"`
+	User        User          `json:"-" gorm:"foreignKey:UserID"`
+	Weeks       []ProgramWeek `json:"weeks" gorm:"foreignKey:ProgramID"`
+}
+
+type ProgramWeek struct {
+	gorm.Model
+	ProgramID  uint         `json:"program_id" gorm:"not null"`
+	WeekNumber int          `json:"week_number" gorm:"not null;check:week_number > 0"`
+	Name       string       `json:"name"`
+	Days       []ProgramDay `json:"days" gorm:"foreignKey:ProgramWeekID"`
+}
+
+// ProgramDay is one session of a program week. A day without a template is a
+// rest day. Its sets override the template's sets of the same exercises with
+// weights taken as a percentage of the lifter's training max.
+type ProgramDay struct {
+	gorm.Model
+	ProgramWeekID uint         `json:"program_week_id" gorm:"not null"`
+	DayNumber     int          `json:"day_number" gorm:"not null;check:day_number > 0"`
+	Name          string       `json:"name"`
+	TemplateID    *uint        `json:"template_id"`
+	Template      *Template    `json:"-" gorm:"foreignKey:TemplateID"`
+	Sets          []ProgramSet `json:"sets" gorm:"foreignKey:ProgramDayID"`
+}
+
+// ProgramSet is a percentage-based set of an exercise on a program day. AMRAP
+// sets ask for as many reps as possible, with Reps as the minimum.
+type ProgramSet struct {
+	gorm.Model
+	ProgramDayID uint     `json:"program_day_id" gorm:"not null"`
+	ExerciseID   uint     `json:"exercise_id" gorm:"not null"`
+	SetNumber    int      `json:"set_number" gorm:"not null;check:set_number > 0"`
+	Percent      float64  `json:"percent" gorm:"not null;check:percent > 0"`
+	Reps         int      `json:"reps" gorm:"not null;check:reps > 0"`
+	AMRAP        bool     `json:"amrap" gorm:"not null;default:false"`
+	Exercise     Exercise `json:"-" gorm:"foreignKey:ExerciseID"`
+}
+
+// ProgramEnrollment is a user running a program. CurrentWeek and CurrentDay
+// point at the next session; CompletedAt is set once the last day is done or
+// the user leaves the program. A user runs at most one program at a time.
+type ProgramEnrollment struct {
+	gorm.Model
+	UserID        uint                 `json:"user_id" gorm:"not null"`
+	ProgramID     uint                 `json:"program_id" gorm:"not null"`
+	CurrentWeek   int                  `json:"current_week" gorm:"not null;default:1"`
+	CurrentDay    int                  `json:"current_day" gorm:"not null;default:1"`
+	StartedAt     time.Time            `json:"started_at" gorm:"not null"`
+	CompletedAt   *time.Time           `json:"completed_at"`
+	Program       Program              `json:"-" gorm:"foreignKey:ProgramID"`
+	TrainingMaxes []ProgramTrainingMax `json:"training_maxes" gorm:"foreignKey:ProgramEnrollmentID"`
+}
+
+// ProgramTrainingMax is the weight, in kg, that an enrollment's percentage
+// based sets of an exercise are taken from.
+type ProgramTrainingMax struct {
+	gorm.Model
+	ProgramEnrollmentID uint     `json:"program_enrollment_id" gorm:"not null"`
+	ExerciseID          uint     `json:"exercise_id" gorm:"not null"`
+	Weight              float64  `json:"weight" gorm:"not null;check:weight > 0"`
+	Exercise            Exercise `json:"-" gorm:"foreignKey:ExerciseID"`
+}
@@ -0,0 +1,32 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Personal record types
+const (
+	RecordMaxWeight     = "max_weight"
+	RecordRepsAtWeight  = "reps_at_weight"
+	RecordEstimated1RM  = "estimated_1rm"
+	RecordSessionVolume = "session_volume"
+)
+
+// PersonalRecord is a personal best set in a workout. Records are kept as a
+// history; IsCurrent marks the ones that have not been beaten since.
+type PersonalRecord struct {
+	gorm.Model
+	UserID         uint      `json:"user_id" gorm:"not null;index:idx_personal_records_user_exercise"`
+	ExerciseID     uint      `json:"exercise_id" gorm:"not null;index:idx_personal_records_user_exercise"`
+	WorkoutID      uint      `json:"workout_id" gorm:"not null;index"`
+	WorkoutEntryID *uint     `json:"workout_entry_id"`
+	Type           string    `json:"type" gorm:"not null"`
+	Value          float64   `json:"value" gorm:"not null"`
+	Weight         float64   `json:"weight"`
+	Reps           int       `json:"reps"`
+	AchievedAt     time.Time `json:"achieved_at" gorm:"not null"`
+	IsCurrent      bool      `json:"is_current" gorm:"not null;default:false"`
+	Exercise       Exercise  `json:"-" gorm:"foreignKey:ExerciseID"`
+}
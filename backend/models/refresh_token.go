@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RefreshToken is a persisted, single-use refresh token. Tokens issued from the
+// same login share a FamilyID; rotating a token revokes it and links it to its
+// replacement, so presenting a revoked token again reveals reuse.
+type RefreshToken struct {
+	gorm.Model
+	UserID       uint       `json:"user_id" gorm:"not null;index"`
+	TokenHash    string     `json:"-" gorm:"not null;uniqueIndex"`
+	FamilyID     string     `json:"family_id" gorm:"not null;index"`
+	ExpiresAt    time.Time  `json:"expires_at" gorm:"not null"`
+	RevokedAt    *time.Time `json:"revoked_at"`
+	ReplacedByID *uint      `json:"replaced_by_id"`
+	User         User       `json:"-" gorm:"foreignKey:UserID"`
+}
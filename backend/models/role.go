@@ -0,0 +1,28 @@
+package models
+
+import "gorm.io/gorm"
+
+// Built-in role names. User.Role references Role.Name.
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
+// Permission names checked by middleware.RequirePermission
+const (
+	PermissionManageExercises = "exercises:manage"
+	PermissionManageUsers     = "users:manage"
+)
+
+type Role struct {
+	gorm.Model
+	Name        string       `json:"name" gorm:"unique;not null"`
+	Description string       `json:"description"`
+	Permissions []Permission `json:"permissions" gorm:"many2many:role_permissions"`
+}
+
+type Permission struct {
+	gorm.Model
+	Name        string `json:"name" gorm:"unique;not null"`
+	Description string `json:"description"`
+}
@@ -2,6 +2,16 @@ package models
 
 import "gorm.io/gorm"
 
+// Tracking types of an exercise, which decide what a logged set records
+const (
+	TrackingWeightReps       = "weight_reps"
+	TrackingRepsOnly         = "reps_only"
+	TrackingDuration         = "duration"
+	TrackingDistanceDuration = "distance_duration"
+)
+
+// Exercise is either part of the system catalog (UserID nil) or a custom
+// exercise private to the user who created it
 type Exercise struct {
 	gorm.Model
 	Name          string `json:"name" gorm:"not null"`
@@ -9,22 +19,84 @@ type Exercise struct {
 	Category      string `json:"category"`
 	PrimaryMuscle string `json:"primary_muscle"`
 	Equipment     string `json:"equipment"`
+	TrackingType  string `json:"tracking_type" gorm:"not null;default:'weight_reps'"`
+	UserID        *uint  `json:"user_id" gorm:"index"`
+	User          *User  `json:"-" gorm:"foreignKey:UserID"`
 }
 
+// Visibilities of a template
+const (
+	VisibilityPrivate  = "private"
+	VisibilityUnlisted = "unlisted"
+	VisibilityPublic   = "public"
+)
+
+// Template is a user's routine. Unlisted and public templates can be viewed
+// and imported by other users through their ShareToken; public ones are also
+// listed in the catalog. An imported template keeps the template it was
+// copied from as its source.
 type Template struct {
 	gorm.Model
-	Name        string             `json:"name" gorm:"not null"`
-	Description string             `json:"description"`
-	UserID      uint               `json:"user_id" gorm:"not null"`
-	User        User               `json:"-" gorm:"foreignKey:UserID"`
-	Exercises   []TemplateExercise `json:"exercises" gorm:"foreignKey:TemplateID"`
+	Name             string             `json:"name" gorm:"not null"`
+	Description      string             `json:"description"`
+	UserID           uint               `json:"user_id" gorm:"not null"`
+	Visibility       string             `json:"visibility" gorm:"not null;default:'private'"`
+	ShareToken       *string            `json:"share_token" gorm:"uniqueIndex"`
+	SourceTemplateID *uint              `json:"source_template_id" gorm:"index"`
+	User             User               `json:"-" gorm:"foreignKey:UserID"`
+	Source           *Template          `json:"-" gorm:"foreignKey:SourceTemplateID"`
+	Exercises        []TemplateExercise `json:"exercises" gorm:"foreignKey:TemplateID"`
 }
 
+// Progression types of a template exercise
+const (
+	ProgressionNone   = "none"
+	ProgressionLinear = "linear"
+	ProgressionDouble = "double"
+)
+
+// Group types of exercises performed together, one set of each per round
+const (
+	GroupSuperset = "superset"
+	GroupCircuit  = "circuit"
+	GroupGiantSet = "giant_set"
+)
+
+// TemplateExercise is one exercise of a template. Its progression rule decides
+// the weight of the next workout from the previous ones: linear adds
+// ProgressionIncrement once every set reaches its target reps, double adds it
+// once every set reaches RepRangeMax, and either deloads by DeloadPercent after
+// DeloadAfter failed workouts in a row. The increment is stored in kg.
+//
+// Consecutive exercises sharing a GroupID form a superset, circuit or giant set
+// performed for GroupRounds rounds, so each has GroupRounds sets. GroupID only
+// identifies the group within its template.
 type TemplateExercise struct {
 	gorm.Model
-	TemplateID uint     `json:"template_id" gorm:"not null"`
-	ExerciseID uint     `json:"exercise_id" gorm:"not null"`
-	Sets       int      `json:"sets" gorm:"not null;check:sets > 0"`
-	Template   Template `json:"-" gorm:"foreignKey:TemplateID"`
-	Exercise   Exercise `json:"exercise" gorm:"foreignKey:ExerciseID"`
+	TemplateID           uint          `json:"template_id" gorm:"not null"`
+	ExerciseID           uint          `json:"exercise_id" gorm:"not null"`
+	Position             int           `json:"position" gorm:"not null;default:0"`
+	Sets                 int           `json:"sets" gorm:"not null;check:sets > 0"`
+	ProgressionType      string        `json:"progression_type" gorm:"not null;default:'none'"`
+	ProgressionIncrement *float64      `json:"progression_increment"`
+	RepRangeMin          *int          `json:"rep_range_min"`
+	RepRangeMax          *int          `json:"rep_range_max"`
+	DeloadAfter          *int          `json:"deload_after"`
+	DeloadPercent        *float64      `json:"deload_percent"`
+	GroupID              *int          `json:"group_id"`
+	GroupType            *string       `json:"group_type"`
+	GroupRounds          *int          `json:"group_rounds"`
+	Template             Template      `json:"-" gorm:"foreignKey:TemplateID"`
+	Exercise             Exercise      `json:"exercise" gorm:"foreignKey:ExerciseID"`
+	SetTargets           []TemplateSet `json:"set_targets" gorm:"foreignKey:TemplateExerciseID"`
+}
+
+// TemplateSet holds the optional targets for a single set of a template exercise
+type TemplateSet struct {
+	gorm.Model
+	TemplateExerciseID uint     `json:"template_exercise_id" gorm:"not null;index"`
+	SetNumber          int      `json:"set_number" gorm:"not null;check:set_number > 0"`
+	TargetReps         *int     `json:"target_reps" gorm:"check:target_reps > 0"`
+	TargetWeight       *float64 `json:"target_weight" gorm:"check:target_weight >= 0"`
+	RestSeconds        *int     `json:"rest_seconds" gorm:"check:rest_seconds >= 0"`
 }
@@ -8,4 +8,8 @@ type User struct {
 	Email    string `json:"email" gorm:"unique;not null"`
 	Password string `json:"-" gorm:"not null"`
 	Role     string `json:"role" gorm:"not null;default:'user'"`
+	// WeightUnit is the unit (kg or lb) weights are shown in and accepted in by default
+	WeightUnit string `json:"weight_unit" gorm:"not null;default:'kg'"`
+	// Timezone is the IANA timezone the user's workouts are dated and grouped in
+	Timezone string `json:"timezone" gorm:"not null;default:'UTC'"`
 }
@@ -1,27 +1,66 @@
 package models
 
 import (
+	"time"
+
 	"gorm.io/gorm"
 )
 
+// Workout is a logged training session. A workout started live has StartedAt
+// set and stays active until FinishedAt is set, at which point
+// DurationMinutes is computed. PerformedAt is when the workout was trained,
+// which may be earlier than when it was logged; history and statistics are
+// dated by it.
 type Workout struct {
 	gorm.Model
-	UserID     uint           `json:"user_id" gorm:"not null"`
-	TemplateID uint           `json:"template_id" gorm:"not null"`
-	Name       string         `json:"name" gorm:"not null"`
-	Notes      string         `json:"notes"`
-	User       User           `json:"-" gorm:"foreignKey:UserID"`
-	Template   Template       `json:"-" gorm:"foreignKey:TemplateID"`
-	Entries    []WorkoutEntry `json:"entries" gorm:"foreignKey:WorkoutID"`
+	UserID          uint           `json:"user_id" gorm:"not null"`
+	TemplateID      uint           `json:"template_id" gorm:"not null"`
+	Name            string         `json:"name" gorm:"not null"`
+	Notes           string         `json:"notes"`
+	PerformedAt     time.Time      `json:"performed_at" gorm:"not null"`
+	StartedAt       *time.Time     `json:"started_at"`
+	FinishedAt      *time.Time     `json:"finished_at"`
+	DurationMinutes *int           `json:"duration_minutes"`
+	User            User           `json:"-" gorm:"foreignKey:UserID"`
+	Template        Template       `json:"-" gorm:"foreignKey:TemplateID"`
+	Entries         []WorkoutEntry `json:"entries" gorm:"foreignKey:WorkoutID"`
+}
+
+// IsActive reports whether the workout is a live session that has not been finished
+func (w Workout) IsActive() bool {
+	return w.StartedAt != nil && w.FinishedAt == nil
 }
 
+// Set types of a workout entry
+const (
+	SetTypeWarmup  = "warmup"
+	SetTypeWorking = "working"
+	SetTypeDrop    = "drop"
+	SetTypeFailure = "failure"
+	SetTypeAMRAP   = "amrap"
+)
+
+// WorkoutEntry is one logged set. Which of reps, weight, duration and distance
+// are set depends on the exercise's tracking type. Effort can be recorded as
+// either RPE (rate of perceived exertion, 1-10) or RIR (reps in reserve).
+// Warm-up sets are kept in the log but left out of statistics and personal
+// records. Sets sharing a GroupID were performed as one superset, circuit or
+// giant set, with the set number as the round.
 type WorkoutEntry struct {
 	gorm.Model
-	WorkoutID  uint     `json:"workout_id" gorm:"not null"`
-	ExerciseID uint     `json:"exercise_id" gorm:"not null"`
-	SetNumber  int      `json:"set_number" gorm:"not null;check:set_number > 0"`
-	Reps       int      `json:"reps" gorm:"not null;check:reps > 0"`
-	Weight     float64  `json:"weight" gorm:"not null;check:weight >= 0"`
-	Workout    Workout  `json:"-" gorm:"foreignKey:WorkoutID"`
-	Exercise   Exercise `json:"exercise" gorm:"foreignKey:ExerciseID"`
+	WorkoutID       uint     `json:"workout_id" gorm:"not null"`
+	ExerciseID      uint     `json:"exercise_id" gorm:"not null"`
+	SetNumber       int      `json:"set_number" gorm:"not null;check:set_number > 0"`
+	SetType         string   `json:"set_type" gorm:"not null;default:'working'"`
+	Reps            int      `json:"reps" gorm:"not null;default:0;check:reps >= 0"`
+	Weight          float64  `json:"weight" gorm:"not null;check:weight >= 0"`
+	DurationSeconds *int     `json:"duration_seconds" gorm:"check:duration_seconds > 0"`
+	DistanceMeters  *float64 `json:"distance_meters" gorm:"check:distance_meters > 0"`
+	RPE             *float64 `json:"rpe" gorm:"check:rpe BETWEEN 1 AND 10"`
+	RIR             *int     `json:"rir" gorm:"check:rir >= 0"`
+	Note            string   `json:"note"`
+	GroupID         *int     `json:"group_id"`
+	GroupType       *string  `json:"group_type"`
+	Workout         Workout  `json:"-" gorm:"foreignKey:WorkoutID"`
+	Exercise        Exercise `json:"exercise" gorm:"foreignKey:ExerciseID"`
 }
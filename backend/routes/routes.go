@@ -1,31 +1,73 @@
 package routes
 
 import (
+	"time"
+
+	"github.com/rachitnimje/trackle-web/config"
 	"github.com/rachitnimje/trackle-web/controllers"
+	"github.com/rachitnimje/trackle-web/metrics"
 	"github.com/rachitnimje/trackle-web/middleware"
+	"github.com/rachitnimje/trackle-web/models"
+	"github.com/rachitnimje/trackle-web/utils"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 )
 
-func SetupRoutes(r *gin.Engine, db *gorm.DB) {
+func SetupRoutes(r *gin.Engine, db *gorm.DB, cfg *config.Config) {
+	jwtManager := utils.NewJWTManager(
+		cfg.Auth.JWTSecret.Reveal(),
+		time.Duration(cfg.Auth.AccessTokenTTL),
+		time.Duration(cfg.Auth.RefreshTokenTTL),
+	)
+
+	// Probe routes
+	r.GET("/healthz", controllers.Healthz)
+	r.GET("/readyz", controllers.Readyz(db))
+	r.GET("/metrics", gin.WrapH(metrics.Handler()))
+
 	// Public routes
 	r.POST("/register", controllers.Register(db))
-	r.POST("/login", controllers.Login(db))
+	r.POST("/login", controllers.Login(db, jwtManager))
+	r.POST("/refresh", controllers.Refresh(db, jwtManager))
 
 	// Protected routes
 	api := r.Group("/api/v1")
-	api.Use(middleware.AuthMiddleware())
+	api.Use(middleware.AuthMiddleware(db, jwtManager))
 	{
 		// User profile routes
 		api.GET("/me", controllers.Me(db))
-		api.POST("/logout", controllers.Logout())
+		api.PATCH("/me/settings", controllers.UpdateUserSettings(db))
+		api.POST("/logout", controllers.Logout(db))
 
 		// User templates routes
 		api.POST("/me/templates", controllers.CreateUserTemplate(db))
 		api.GET("/me/templates", controllers.GetAllUserTemplates(db))
 		api.GET("/me/templates/:id", controllers.GetUserTemplate(db))
+		api.PUT("/me/templates/:id", controllers.UpdateUserTemplate(db))
+		api.PATCH("/me/templates/:id", controllers.PatchUserTemplate(db))
 		api.DELETE("/me/templates/:id", controllers.DeleteUserTemplate(db))
+		api.GET("/me/templates/:id/next-workout", controllers.GetNextUserWorkout(db))
+		api.POST("/me/templates/:id/clone", controllers.CloneUserTemplate(db))
+		api.PUT("/me/templates/:id/visibility", controllers.UpdateTemplateVisibility(db))
+
+		// Shared template routes (public catalog and share links)
+		api.GET("/templates/public", controllers.GetPublicTemplates(db))
+		api.GET("/templates/shared/:token", controllers.GetSharedTemplate(db))
+		api.POST("/templates/shared/:token/import", controllers.ImportSharedTemplate(db))
+
+		// Training program routes
+		api.POST("/me/programs", controllers.CreateUserProgram(db))
+		api.GET("/me/programs", controllers.GetAllUserPrograms(db))
+		api.GET("/me/programs/:id", controllers.GetUserProgram(db))
+		api.PUT("/me/programs/:id", controllers.UpdateUserProgram(db))
+		api.DELETE("/me/programs/:id", controllers.DeleteUserProgram(db))
+		api.POST("/me/programs/:id/enroll", controllers.EnrollUserProgram(db))
+		api.GET("/me/program", controllers.GetUserProgramEnrollment(db))
+		api.GET("/me/program/today", controllers.GetTodayProgramSession(db))
+		api.POST("/me/program/advance", controllers.AdvanceUserProgram(db))
+		api.PUT("/me/program/training-maxes", controllers.UpdateProgramTrainingMaxes(db))
+		api.DELETE("/me/program", controllers.LeaveUserProgram(db))
 
 		// User workouts routes
 		api.POST("/me/workouts", controllers.CreateUserWorkout(db))
@@ -33,8 +75,22 @@ func SetupRoutes(r *gin.Engine, db *gorm.DB) {
 		api.GET("/me/workouts/:id", controllers.GetUserWorkout(db))
 		api.PUT("/me/workouts/:id", controllers.UpdateUserWorkout(db))
 		api.DELETE("/me/workouts/:id", controllers.DeleteUserWorkout(db))
+		api.POST("/me/workouts/:id/save-as-template", controllers.SaveWorkoutAsTemplate(db))
 
-		// Exercise routes (general resources)
+		// Live workout session routes
+		api.POST("/me/workouts/start", controllers.StartUserWorkout(db))
+		api.GET("/me/workouts/active", controllers.GetActiveUserWorkout(db))
+		api.POST("/me/workouts/:id/entries", controllers.AddSessionEntry(db))
+		api.PUT("/me/workouts/:id/entries/:entryId", controllers.UpdateSessionEntry(db))
+		api.DELETE("/me/workouts/:id/entries/:entryId", controllers.DeleteSessionEntry(db))
+		api.POST("/me/workouts/:id/finish", controllers.FinishUserWorkout(db))
+
+		// Data export and import
+		api.GET("/me/export", controllers.ExportUserWorkouts(db))
+		api.POST("/me/import", controllers.ImportUserWorkouts(db))
+
+		// Exercise routes (system catalog plus the user's custom exercises;
+		// handlers restrict changes to the owner or an admin)
 		api.GET("/exercises", controllers.GetAllExercises(db))
 		api.POST("/exercises", controllers.CreateExercise(db))
 		api.GET("/exercises/:id", controllers.GetExercise(db))
@@ -45,10 +101,20 @@ func SetupRoutes(r *gin.Engine, db *gorm.DB) {
 		api.GET("/exercises/categories", controllers.GetExerciseCategories)
 		api.GET("/exercises/muscles", controllers.GetPrimaryMuscles)
 		api.GET("/exercises/equipment", controllers.GetEquipmentTypes)
+		api.GET("/exercises/tracking-types", controllers.GetTrackingTypes)
 		
 		// Statistics routes
 		api.GET("/stats/workouts", controllers.GetWorkoutStats(db))
 		api.GET("/stats/exercises/:id", controllers.GetExerciseProgress(db))
 		api.GET("/stats/aggregate", controllers.GetAggregateStats(db))
+		api.GET("/stats/records", controllers.GetPersonalRecords(db))
+	}
+
+	// Admin routes
+	admin := api.Group("/admin")
+	admin.Use(middleware.RequireRole(models.RoleAdmin))
+	{
+		admin.GET("/roles", controllers.GetAllRoles(db))
+		admin.PUT("/users/:id/role", middleware.RequirePermission(db, models.PermissionManageUsers), controllers.UpdateUserRole(db))
 	}
 }
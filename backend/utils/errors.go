@@ -3,11 +3,15 @@ package utils
 import (
 	"errors"
 	"fmt"
-	"log"
 	"net/http"
 	"runtime"
+
+	"github.com/jackc/pgx/v5/pgconn"
 )
 
+// uniqueViolation is the Postgres error code for a unique constraint violation
+const uniqueViolation = "23505"
+
 // Error types
 var (
 	ErrValidation       = errors.New("validation error")
@@ -40,13 +44,16 @@ func (e *AppError) Error() string {
 
 // NewError creates a new AppError
 func NewError(errType error, statusCode int, message string, err error) *AppError {
-	pc, file, line, _ := runtime.Caller(1)
+	return newError(errType, statusCode, message, err)
+}
+
+// newError creates an AppError recording where the exported constructor that
+// called it was called from
+func newError(errType error, statusCode int, message string, err error) *AppError {
+	pc, file, line, _ := runtime.Caller(2)
 	funcName := runtime.FuncForPC(pc).Name()
 	stack := fmt.Sprintf("%s:%d %s", file, line, funcName)
 
-	// Log the error with stack trace
-	logError(errType, message, err, stack)
-
 	return &AppError{
 		Type:       errType,
 		Message:    message,
@@ -58,48 +65,44 @@ func NewError(errType error, statusCode int, message string, err error) *AppErro
 
 // Helper functions for common errors
 func NewValidationError(message string, err error) *AppError {
-	return NewError(ErrValidation, http.StatusBadRequest, message, err)
+	return newError(ErrValidation, http.StatusBadRequest, message, err)
 }
 
 func NewAuthenticationError(message string, err error) *AppError {
-	return NewError(ErrAuthentication, http.StatusUnauthorized, message, err)
+	return newError(ErrAuthentication, http.StatusUnauthorized, message, err)
 }
 
 func NewAuthorizationError(message string, err error) *AppError {
-	return NewError(ErrAuthorization, http.StatusForbidden, message, err)
+	return newError(ErrAuthorization, http.StatusForbidden, message, err)
 }
 
 func NewNotFoundError(message string, err error) *AppError {
-	return NewError(ErrNotFound, http.StatusNotFound, message, err)
+	return newError(ErrNotFound, http.StatusNotFound, message, err)
 }
 
 func NewDuplicateEntryError(message string, err error) *AppError {
-	return NewError(ErrDuplicateEntry, http.StatusConflict, message, err)
+	return newError(ErrDuplicateEntry, http.StatusConflict, message, err)
 }
 
 func NewDatabaseError(message string, err error) *AppError {
-	return NewError(ErrDatabase, http.StatusInternalServerError, message, err)
+	return newError(ErrDatabase, http.StatusInternalServerError, message, err)
 }
 
 func NewInternalError(message string, err error) *AppError {
-	return NewError(ErrInternal, http.StatusInternalServerError, message, err)
+	return newError(ErrInternal, http.StatusInternalServerError, message, err)
 }
 
 func NewInvalidInputError(message string, err error) *AppError {
-	return NewError(ErrInvalidInput, http.StatusBadRequest, message, err)
+	return newError(ErrInvalidInput, http.StatusBadRequest, message, err)
 }
 
 func NewExternalServiceError(message string, err error) *AppError {
-	return NewError(ErrExternalService, http.StatusInternalServerError, message, err)
+	return newError(ErrExternalService, http.StatusInternalServerError, message, err)
 }
 
-// logError logs an error with context
-func logError(errType error, message string, err error, stack string) {
-	// In production, this should use a proper logging framework
-	log.Printf("[ERROR] Type: %s, Message: %s, Original Error: %v\nStack: %s\n", 
-		errType.Error(), 
-		message, 
-		err, 
-		stack,
-	)
+// IsUniqueViolation reports whether err comes from a unique index or constraint
+// rejecting a row
+func IsUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == uniqueViolation
 }
@@ -0,0 +1,115 @@
+package utils
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// exerciseNameSynonyms expands the abbreviations other apps use in exercise names
+var exerciseNameSynonyms = map[string]string{
+	"db":        "dumbbell",
+	"dumbbells": "dumbbell",
+	"bb":        "barbell",
+	"kb":        "kettlebell",
+	"bw":        "bodyweight",
+	"ohp":       "overhead press",
+	"rdl":       "romanian deadlift",
+	"pullup":    "pull up",
+	"pullups":   "pull up",
+	"chinup":    "chin up",
+	"chinups":   "chin up",
+	"pushup":    "push up",
+	"pushups":   "push up",
+}
+
+// exerciseNameTokens splits an exercise name into lowercase words with
+// punctuation removed, abbreviations expanded and plurals trimmed
+func exerciseNameTokens(name string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	var tokens []string
+	for _, field := range fields {
+		if expanded, ok := exerciseNameSynonyms[field]; ok {
+			tokens = append(tokens, strings.Fields(expanded)...)
+			continue
+		}
+		if len(field) > 3 && strings.HasSuffix(field, "s") && !strings.HasSuffix(field, "ss") {
+			field = strings.TrimSuffix(field, "s")
+		}
+		tokens = append(tokens, field)
+	}
+	return tokens
+}
+
+// NormalizeExerciseName returns a canonical form of an exercise name, so that
+// "Bench Press (Barbell)" and "barbell bench press" normalize the same
+func NormalizeExerciseName(name string) string {
+	tokens := exerciseNameTokens(name)
+	sort.Strings(tokens)
+	return strings.Join(tokens, " ")
+}
+
+// ExerciseNameSimilarity scores how alike two exercise names are, from 0 to 1.
+// It takes the better of word overlap (ignoring word order) and edit distance
+// between the normalized names, which catches typos and spelling variants.
+func ExerciseNameSimilarity(a, b string) float64 {
+	tokensA, tokensB := exerciseNameTokens(a), exerciseNameTokens(b)
+	if len(tokensA) == 0 || len(tokensB) == 0 {
+		return 0
+	}
+
+	setA := make(map[string]bool, len(tokensA))
+	for _, token := range tokensA {
+		setA[token] = true
+	}
+	setB := make(map[string]bool, len(tokensB))
+	for _, token := range tokensB {
+		setB[token] = true
+	}
+
+	shared := 0
+	for token := range setA {
+		if setB[token] {
+			shared++
+		}
+	}
+	jaccard := float64(shared) / float64(len(setA)+len(setB)-shared)
+
+	normalizedA, normalizedB := NormalizeExerciseName(a), NormalizeExerciseName(b)
+	longest := len([]rune(normalizedA))
+	if n := len([]rune(normalizedB)); n > longest {
+		longest = n
+	}
+	edit := 1 - float64(levenshtein(normalizedA, normalizedB))/float64(longest)
+
+	if edit > jaccard {
+		return edit
+	}
+	return jaccard
+}
+
+// levenshtein returns the edit distance between two strings
+func levenshtein(a, b string) int {
+	runesA, runesB := []rune(a), []rune(b)
+	previous := make([]int, len(runesB)+1)
+	current := make([]int, len(runesB)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(runesA); i++ {
+		current[0] = i
+		for j := 1; j <= len(runesB); j++ {
+			cost := 1
+			if runesA[i-1] == runesB[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(runesB)]
+}
@@ -2,41 +2,67 @@ package utils
 
 import (
 	"errors"
-	"github.com/joho/godotenv"
-	"log"
-	"os"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
-var jwtSecret = getJWTSecret()
+// Default token lifetimes. Access tokens are kept short because they are only
+// revoked through their session; refresh tokens are rotated on every use.
+const (
+	AccessTokenTTL  = 15 * time.Minute
+	RefreshTokenTTL = 7 * 24 * time.Hour
+)
 
-// getJWTSecret retrieves the JWT secret from the environment variable and panics if the secret is not set
-func getJWTSecret() []byte {
-	var err = godotenv.Load()
-	if err != nil {
-		log.Fatal("Error loading .env file")
-	}
+type JWTClaims struct {
+	UserID    uint   `json:"user_id"`
+	Role      string `json:"role"`
+	SessionID string `json:"sid"`
+	jwt.RegisteredClaims
+}
 
-	secret := os.Getenv("JWT_SECRET")
+// JWTManager signs and validates access tokens with the configured secret and
+// knows the lifetimes of access and refresh tokens
+type JWTManager struct {
+	secret          []byte
+	accessTokenTTL  time.Duration
+	refreshTokenTTL time.Duration
+}
 
-	if secret == "" {
-		panic("JWT_SECRET environment variable is not set. Application cannot start.")
+// NewJWTManager creates a JWTManager. Zero lifetimes fall back to the defaults.
+func NewJWTManager(secret string, accessTokenTTL, refreshTokenTTL time.Duration) *JWTManager {
+	if accessTokenTTL <= 0 {
+		accessTokenTTL = AccessTokenTTL
+	}
+	if refreshTokenTTL <= 0 {
+		refreshTokenTTL = RefreshTokenTTL
+	}
+	return &JWTManager{
+		secret:          []byte(secret),
+		accessTokenTTL:  accessTokenTTL,
+		refreshTokenTTL: refreshTokenTTL,
 	}
-	return []byte(secret)
 }
 
-type JWTClaims struct {
-	UserID uint `json:"user_id"`
-	jwt.RegisteredClaims
+// AccessTokenTTL returns how long an access token is valid
+func (m *JWTManager) AccessTokenTTL() time.Duration {
+	return m.accessTokenTTL
+}
+
+// RefreshTokenTTL returns how long a refresh token is valid
+func (m *JWTManager) RefreshTokenTTL() time.Duration {
+	return m.refreshTokenTTL
 }
 
-func GenerateJWT(userID uint) (string, error) {
-	expirationTime := time.Now().Add(24 * time.Hour)
+// Generate issues a short-lived access token carrying the user's role and
+// bound to the given session (the refresh token family it was issued alongside)
+func (m *JWTManager) Generate(userID uint, role string, sessionID string) (string, error) {
+	expirationTime := time.Now().Add(m.accessTokenTTL)
 
 	claims := &JWTClaims{
-		UserID: userID,
+		UserID:    userID,
+		Role:      role,
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(expirationTime),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
@@ -46,17 +72,17 @@ func GenerateJWT(userID uint) (string, error) {
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(jwtSecret)
+	return token.SignedString(m.secret)
 }
 
-func ValidateJWT(tokenStr string) (*JWTClaims, error) {
+func (m *JWTManager) Validate(tokenStr string) (*JWTClaims, error) {
 	claims := &JWTClaims{}
 
 	token, err := jwt.ParseWithClaims(tokenStr, claims, func(token *jwt.Token) (interface{}, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, errors.New("unexpected signing method")
 		}
-		return jwtSecret, nil
+		return m.secret, nil
 	})
 
 	if err != nil {
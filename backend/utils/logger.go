@@ -0,0 +1,66 @@
+package utils
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RequestIDHeader carries a request's ID in and out of the API
+const RequestIDHeader = "X-Request-ID"
+
+// NewLogger returns a logger writing JSON lines at or above the given level
+func NewLogger(w io.Writer, level string) (*slog.Logger, error) {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return nil, fmt.Errorf("invalid log level %q: %w", level, err)
+	}
+	return slog.New(slog.NewJSONHandler(w, &slog.HandlerOptions{Level: lvl})), nil
+}
+
+// GenerateRequestID returns a random identifier for a request without one
+func GenerateRequestID() (string, error) {
+	return randomString(12)
+}
+
+// ValidRequestID reports whether an incoming request ID is safe to reuse: short
+// and limited to characters that cannot break a log line or a header
+func ValidRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	return strings.IndexFunc(id, func(r rune) bool {
+		return r < '!' || r > '~'
+	}) == -1
+}
+
+// RequestLogger returns the default logger annotated with the request's ID,
+// trace ID when traced, method, route template and, once authenticated, user ID
+func RequestLogger(c *gin.Context) *slog.Logger {
+	logger := slog.Default()
+	if c == nil {
+		return logger
+	}
+
+	attrs := make([]any, 0, 8)
+	if requestID := c.GetString("request_id"); requestID != "" {
+		attrs = append(attrs, "request_id", requestID)
+	}
+	if c.Request != nil {
+		if spanContext := trace.SpanContextFromContext(c.Request.Context()); spanContext.HasTraceID() {
+			attrs = append(attrs, "trace_id", spanContext.TraceID().String())
+		}
+		attrs = append(attrs, "method", c.Request.Method)
+	}
+	if route := c.FullPath(); route != "" {
+		attrs = append(attrs, "route", route)
+	}
+	if userID, exists := c.Get("user_id"); exists {
+		attrs = append(attrs, "user_id", userID)
+	}
+	return logger.With(attrs...)
+}
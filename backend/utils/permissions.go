@@ -0,0 +1,21 @@
+package utils
+
+import "gorm.io/gorm"
+
+// HasPermission reports whether the named role has been granted a permission
+func HasPermission(db *gorm.DB, role string, permission string) (bool, error) {
+	if role == "" {
+		return false, nil
+	}
+
+	var count int64
+	err := db.Table("role_permissions").
+		Joins("JOIN roles ON roles.id = role_permissions.role_id AND roles.deleted_at IS NULL").
+		Joins("JOIN permissions ON permissions.id = role_permissions.permission_id AND permissions.deleted_at IS NULL").
+		Where("roles.name = ? AND permissions.name = ?", role, permission).
+		Count(&count).Error
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
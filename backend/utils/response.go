@@ -2,7 +2,8 @@ package utils
 
 import (
 	"errors"
-	"log"
+	"fmt"
+	"log/slog"
 	"net/http"
 	"runtime"
 
@@ -27,12 +28,8 @@ func CreatedResponse(c *gin.Context, message string, data interface{}) {
 }
 
 func ErrorResponse(c *gin.Context, statusCode int, message string, err error) {
-	// For debugging only - log the full error
-	if err != nil {
-		_, file, line, _ := runtime.Caller(1)
-		log.Printf("[DEBUG] Error at %s:%d: %v", file, line, err)
-	}
-	
+	logErrorResponse(c, statusCode, message, err)
+
 	// Check if it's our custom AppError
 	var appError *AppError
 	if errors.As(err, &appError) {
@@ -83,3 +80,37 @@ func PaginatedResponse(c *gin.Context, message string, data interface{}, page, l
 		HasPrev:    hasPrev,
 	})
 }
+
+// logErrorResponse logs an error response through the request's logger, at
+// error level for server errors and warning level for client errors
+func logErrorResponse(c *gin.Context, statusCode int, message string, err error) {
+	level := slog.LevelWarn
+	attrs := []slog.Attr{slog.String("message", message)}
+
+	var appError *AppError
+	if errors.As(err, &appError) {
+		statusCode = appError.StatusCode
+		attrs[0] = slog.String("message", appError.Message)
+		attrs = append(attrs,
+			slog.String("error_type", appError.Type.Error()),
+			slog.String("caller", appError.Stack),
+		)
+		if appError.Err != nil {
+			attrs = append(attrs, slog.String("error", appError.Err.Error()))
+		}
+	} else {
+		// the caller of ErrorResponse
+		_, file, line, _ := runtime.Caller(2)
+		attrs = append(attrs, slog.String("caller", fmt.Sprintf("%s:%d", file, line)))
+		if err != nil {
+			attrs = append(attrs, slog.String("error", err.Error()))
+		}
+	}
+
+	if statusCode >= http.StatusInternalServerError {
+		level = slog.LevelError
+	}
+	attrs = append(attrs, slog.Int("status", statusCode))
+
+	RequestLogger(c).LogAttrs(c.Request.Context(), level, "request failed", attrs...)
+}
@@ -0,0 +1,47 @@
+package utils
+
+import "time"
+
+// Time series bucket sizes
+const (
+	BucketDay   = "day"
+	BucketWeek  = "week"
+	BucketMonth = "month"
+)
+
+// BucketStart truncates t to the start of its day, ISO week (Monday) or month,
+// in t's location
+func BucketStart(t time.Time, bucket string) time.Time {
+	year, month, day := t.Date()
+	switch bucket {
+	case BucketMonth:
+		return time.Date(year, month, 1, 0, 0, 0, 0, t.Location())
+	case BucketWeek:
+		offset := (int(t.Weekday()) + 6) % 7 // days since Monday
+		return time.Date(year, month, day-offset, 0, 0, 0, 0, t.Location())
+	default:
+		return time.Date(year, month, day, 0, 0, 0, 0, t.Location())
+	}
+}
+
+// MovingAverage smooths values with a trailing window of the given size. The
+// first points average over however many values are available.
+func MovingAverage(values []float64, window int) []float64 {
+	if window < 1 {
+		window = 1
+	}
+	smoothed := make([]float64, len(values))
+	var sum float64
+	for i, v := range values {
+		sum += v
+		if i >= window {
+			sum -= values[i-window]
+		}
+		n := i + 1
+		if n > window {
+			n = window
+		}
+		smoothed[i] = sum / float64(n)
+	}
+	return smoothed
+}
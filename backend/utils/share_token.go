@@ -0,0 +1,6 @@
+package utils
+
+// GenerateShareToken returns a random, URL-safe token for a share link
+func GenerateShareToken() (string, error) {
+	return randomString(16)
+}
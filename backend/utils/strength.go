@@ -0,0 +1,27 @@
+package utils
+
+// One-rep-max estimation formulas
+const (
+	FormulaEpley   = "epley"
+	FormulaBrzycki = "brzycki"
+)
+
+// EstimateOneRepMax estimates a one-rep max from a set using the Epley formula
+func EstimateOneRepMax(weight float64, reps int) float64 {
+	return EstimateOneRepMaxWith(FormulaEpley, weight, reps)
+}
+
+// EstimateOneRepMaxWith estimates a one-rep max from a set using the named
+// formula. Brzycki is undefined from 37 reps up, where Epley is used instead.
+func EstimateOneRepMaxWith(formula string, weight float64, reps int) float64 {
+	if reps <= 0 {
+		return 0
+	}
+	if reps == 1 {
+		return weight
+	}
+	if formula == FormulaBrzycki && reps < 37 {
+		return weight * 36 / float64(37-reps)
+	}
+	return weight * (1 + float64(reps)/30)
+}
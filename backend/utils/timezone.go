@@ -0,0 +1,39 @@
+package utils
+
+import (
+	"time"
+	// embedded so timezones resolve on hosts without a zoneinfo database
+	_ "time/tzdata"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// DefaultTimezone is the timezone of users who have not chosen one
+const DefaultTimezone = "UTC"
+
+// LoadTimezone returns the location of an IANA timezone name such as
+// "Europe/Berlin", falling back to UTC when the name is empty or unknown
+func LoadTimezone(name string) *time.Location {
+	if name == "" {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// IsValidTimezone reports whether name is a known IANA timezone name
+func IsValidTimezone(name string) bool {
+	if name == "" || name == "Local" {
+		return false
+	}
+	_, err := time.LoadLocation(name)
+	return err == nil
+}
+
+// ValidateTimezone validates that a field is a known IANA timezone name
+func ValidateTimezone(fl validator.FieldLevel) bool {
+	return IsValidTimezone(fl.Field().String())
+}
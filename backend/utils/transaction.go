@@ -1,36 +1,46 @@
 package utils
 
 import (
+	"fmt"
+	"runtime/debug"
+
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 )
 
-// TransactionManager handles database transactions with proper error handling
-func TransactionManager(db *gorm.DB, c *gin.Context, fn func(*gorm.DB) error) {
+// TransactionManager handles database transactions with proper error handling.
+// It returns nil only once the transaction has committed. A failed commit or a
+// panic is written as an error response; an error returned by fn is not, so
+// the caller writes it.
+func TransactionManager(db *gorm.DB, c *gin.Context, fn func(*gorm.DB) error) (err error) {
 	tx := db.Begin()
 	
 	// Ensure rollback on panic
 	defer func() {
 		if r := recover(); r != nil {
 			tx.Rollback()
+			RequestLogger(c).Error("panic in transaction", "panic", fmt.Sprint(r), "stack", string(debug.Stack()))
 			// Convert panic to error response
 			appErr := NewInternalError("Internal server error", nil)
 			ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+			err = appErr
 		}
 	}()
 
 	// Execute the transaction function
-	err := fn(tx)
-	if err != nil {
+	if err := fn(tx); err != nil {
 		tx.Rollback()
+		RequestLogger(c).Debug("transaction rolled back", "error", err.Error())
 		
-		// The error is already handled in the function
-		return
+		// The error is left to the caller to handle
+		return err
 	}
 
 	// Commit the transaction
 	if err := tx.Commit().Error; err != nil {
 		appErr := NewDatabaseError("Failed to commit transaction", err)
 		ErrorResponse(c, appErr.StatusCode, appErr.Message, appErr)
+		return appErr
 	}
+	return nil
 }
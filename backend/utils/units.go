@@ -0,0 +1,53 @@
+package utils
+
+import "math"
+
+// Weight units. Weights are stored in kilograms and converted at the edges.
+const (
+	UnitKg = "kg"
+	UnitLb = "lb"
+)
+
+const kgPerLb = 0.45359237
+
+// Smallest weight steps that can be loaded with fractional plates
+const (
+	plateStepKg = 0.25
+	plateStepLb = 0.5
+)
+
+// IsValidWeightUnit reports whether unit is a supported weight unit
+func IsValidWeightUnit(unit string) bool {
+	return unit == UnitKg || unit == UnitLb
+}
+
+// ToKg converts a weight given in unit to kilograms
+func ToKg(weight float64, unit string) float64 {
+	if unit == UnitLb {
+		return weight * kgPerLb
+	}
+	return weight
+}
+
+// ConvertWeight converts a weight in kilograms to unit, rounded to two decimals.
+// Use it for derived values such as volume or estimated maxes.
+func ConvertWeight(kg float64, unit string) float64 {
+	weight := kg
+	if unit == UnitLb {
+		weight = kg / kgPerLb
+	}
+	return math.Round(weight*100) / 100
+}
+
+// DisplayWeight converts a weight in kilograms to unit and rounds it to the
+// nearest loadable plate step (0.25 kg or 0.5 lb). Use it for lifted and
+// prescribed set weights.
+func DisplayWeight(kg float64, unit string) float64 {
+	step := plateStepKg
+	weight := kg
+	if unit == UnitLb {
+		step = plateStepLb
+		weight = kg / kgPerLb
+	}
+	return math.Round(weight/step) * step
+}
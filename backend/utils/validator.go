@@ -15,6 +15,7 @@ func InitValidator() {
 		// Register custom validation for strong password
 		v.RegisterValidation("strongpassword", ValidateStrongPassword)
 		v.RegisterValidation("username", ValidateUsername)
+		v.RegisterValidation("timezone", ValidateTimezone)
 	}
 }
 
@@ -32,6 +32,10 @@ func fieldErrorToText(e validator.FieldError) string {
 		return "Password must be at least 8 characters and include uppercase, lowercase, number, and special character"
 	case "username":
 		return "Username must be 3-30 characters, alphanumeric and underscore only"
+	case "timezone":
+		return fmt.Sprintf("%s must be an IANA timezone such as Europe/Berlin", field)
+	case "oneof":
+		return fmt.Sprintf("%s must be one of: %s", field, strings.ReplaceAll(e.Param(), " ", ", "))
 	default:
 		return fmt.Sprintf("%s failed on the %s tag", field, e.Tag())
 	}